@@ -146,6 +146,53 @@ func TestCommander(t *testing.T) {
 			assert.Error(t, app.Run(ctx, []string{"foo", "error"}))
 			assert.Error(t, app.Run(ctx, []string{"foo"}))
 		})
+		t.Run("SubcommandWithParent", func(t *testing.T) {
+			t.Run("Chain", func(t *testing.T) {
+				count := 0
+				cmd := MakeRootCommander()
+				AddOperationSpec(cmd, SpecBuilder(func(ctx context.Context, cc *cli.Command) (string, error) {
+					count++
+					return cc.String("root"), nil
+				}).StoreInContext())
+				cmd.Flags(MakeFlag(&FlagOptions[string]{Name: "root"}))
+
+				sub := SubcommandWithParent(cmd,
+					func(ctx context.Context, parent string, cc *cli.Command) (int, error) {
+						count++
+						check.Equal(t, parent, "kip")
+						return len(parent), nil
+					},
+					func(ctx context.Context, in int) error { t.Error("should not run"); return nil },
+				).SetName("sub")
+
+				SubcommandWithParent(sub,
+					func(ctx context.Context, parent int, cc *cli.Command) (int, error) {
+						count++
+						check.Equal(t, parent, 3)
+						return parent * 2, nil
+					},
+					func(ctx context.Context, in int) error {
+						count++
+						check.Equal(t, in, 6)
+						return nil
+					},
+				).SetName("leaf")
+
+				assert.NotError(t, Run(ctx, cmd, []string{t.Name(), "--root", "kip", "sub", "leaf"}))
+				assert.Equal(t, count, 4)
+			})
+			t.Run("MissingParent", func(t *testing.T) {
+				cmd := MakeRootCommander()
+				SubcommandWithParent(cmd,
+					func(ctx context.Context, parent string, cc *cli.Command) (string, error) { return parent, nil },
+					func(ctx context.Context, in string) error { t.Error("should not run"); return nil },
+				).SetName("sub")
+
+				err := Run(ctx, cmd, []string{t.Name(), "sub"})
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, ErrNotSet)
+			})
+		})
 		t.Run("OperationSpec", func(t *testing.T) {
 			t.Run("Basic", func(t *testing.T) {
 				count := 0
@@ -355,9 +402,11 @@ func TestCommander(t *testing.T) {
 						return nil
 					}).
 					Flags(MakeFlag(&FlagOptions[string]{Name: "hello"}))
-				assert.Panic(t, func() {
-					_ = Run(ctx, cmd, []string{t.Name(), "--hello", "kip"})
+				var err error
+				assert.NotPanic(t, func() {
+					err = Run(ctx, cmd, []string{t.Name(), "--hello", "kip"})
 				})
+				assert.ErrorIs(t, err, ErrCrashed)
 
 				assert.Equal(t, count, 1)
 			})
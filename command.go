@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sync"
 	"sync/atomic"
 
@@ -80,12 +81,27 @@ type Commander struct {
 	opts       adt.Atomic[AppOptions]
 	name       adt.Atomic[string]
 	usage      adt.Atomic[string]
+	desc       adt.Atomic[string]
+	category   adt.Atomic[string]
 	action     adt.Atomic[Action]
 	flags      adt.Synchronized[*dt.List[Flag]]
 	aliases    adt.Synchronized[*dt.List[string]]
 	hook       adt.Synchronized[*dt.List[Action]]
 	middleware adt.Synchronized[*dt.List[Middleware]]
+	ordered    adt.Synchronized[*dt.List[MiddlewareOptions]]
 	subcmds    adt.Synchronized[*dt.List[*Commander]]
+	providers  adt.SyncMap[reflect.Type, provider]
+	resolvers  adt.Synchronized[*dt.List[Attachment]]
+	completers adt.Synchronized[*dt.List[completer]]
+	shortcuts  adt.SyncMap[string, []string]
+	signals    adt.Synchronized[*dt.List[signalHandler]]
+	prompts    adt.Atomic[*PromptOptions]
+	streams    adt.Synchronized[*Streams]
+	examples   adt.Synchronized[*dt.List[Example]]
+	help       adt.Atomic[*HelpOptions]
+
+	interceptors adt.Synchronized[*dt.List[Interceptor]]
+	inherited    adt.Atomic[[]Interceptor]
 
 	// this has to be a context producer (func() context.Context)
 	// so that the interior atomic doesn't freak out when the
@@ -126,23 +142,53 @@ func MakeCommander() *Commander {
 	c.hook.Set(&dt.List[Action]{})
 	c.subcmds.Set(&dt.List[*Commander]{})
 	c.middleware.Set(&dt.List[Middleware]{})
+	c.ordered.Set(&dt.List[MiddlewareOptions]{})
 	c.aliases.Set(&dt.List[string]{})
+	c.resolvers.Set(&dt.List[Attachment]{})
+	c.completers.Set(&dt.List[completer]{})
+	c.interceptors.Set(&dt.List[Interceptor]{})
+	c.signals.Set(&dt.List[signalHandler]{})
+	c.streams.Set(&Streams{})
+	c.examples.Set(&dt.List[Example]{})
 
 	c.cmd.Before = func(ctx context.Context, cc *cli.Command) (context.Context, error) {
 		var ec erc.Collector
 
+		c.setContext(attachStreams(c.getContext(), cc))
+		c.setContext(c.attachPrompter(c.getContext(), cc))
+		if err := c.runPrompts(c.getContext(), cc); err != nil {
+			return c.getContext(), err
+		}
+
+		c.setContext(c.attachProviders(c.getContext()))
+		c.setContext(c.attachReloader(c.getContext()))
+
 		c.hook.With(func(hooks *dt.List[Action]) {
+			idx := 0
 			for op := range hooks.IteratorFront() {
-				ec.Push(op(c.getContext(), cc))
+				ctx, end := startPhase(c.getContext(), fmt.Sprint("hook ", idx), cc)
+				err := op(ctx, cc)
+				end(err)
+				ec.Push(err)
+				idx++
 			}
 		})
 
 		c.middleware.With(func(in *dt.List[Middleware]) {
+			idx := 0
 			for op := range in.IteratorFront() {
+				_, end := startPhase(c.getContext(), fmt.Sprint("middleware ", idx), cc)
 				c.setContext(op(c.getContext()))
+				end(nil)
+				idx++
 			}
 		})
 
+		ec.Push(c.runOrderedMiddleware(cc))
+
+		c.registerProviderCleanup(c.getContext())
+		ec.Push(c.startSignalHandlers(c.getContext()))
+
 		c.flags.With(func(flags *dt.List[Flag]) {
 			for flag := range flags.IteratorFront() {
 				if af, ok := flag.value.(cli.ActionableFlag); ok {
@@ -159,7 +205,7 @@ func MakeCommander() *Commander {
 
 		switch {
 		case op != nil:
-			return op(c.getContext(), cc)
+			return traceAction(c.getContext(), cc, op)
 		case c.subcmds.Get().Len() == 0:
 			return fmt.Errorf("action: %w", ErrNotDefined)
 		case cc.Args().Len() == 0:
@@ -180,6 +226,18 @@ func (c *Commander) SetHidden(in bool) *Commander     { c.hidden.Store(in); retu
 func (c *Commander) EnableCompletionCmd() *Commander  { c.enableShellCompletion.Store(true); return c }
 func (c *Commander) DisableCompletionCmd() *Commander { c.enableShellCompletion.Store(false); return c }
 
+// SetDescription sets the long description of the command, which
+// the help shows after the usage.
+func (c *Commander) SetDescription(d string) *Commander { c.desc.Set(d); return c }
+
+// SetCategory groups the command, in the help of its parent, with
+// the other subcommands of the same category.
+func (c *Commander) SetCategory(cat string) *Commander { c.category.Set(cat); return c }
+
+// Examples adds examples of the use of the command to its help (see
+// HelpOptions.)
+func (c *Commander) Examples(ex ...Example) *Commander { appendTo(&c.examples, ex...); return c }
+
 // SetBlocking configures the blocking semantics of the command. This
 // setting is only used by root Commander objects. It defaults to
 // false, which means that the action function returns the context
@@ -256,6 +314,20 @@ func (c *Commander) Middleware(mws ...Middleware) *Commander {
 // chain directly.
 func (c *Commander) With(op Attachment) *Commander { op(c); return c }
 
+// onResolve defers an attachment until the commander is resolved
+// into a cli.Command, for helpers that depend on the final state of
+// the commander (e.g. its name or subcommands.)
+func (c *Commander) onResolve(op Attachment) *Commander { pushTo(&c.resolvers, op); return c }
+
+// completer observes the outcome of every invocation of a root
+// command, with the full name of the command that ran.
+type completer func(ctx context.Context, command string, err error)
+
+// onComplete registers a completer, which Run calls when the command
+// returns, including when it fails before its hooks run (e.g. when
+// flags are not valid.)
+func (c *Commander) onComplete(op completer) *Commander { pushTo(&c.completers, op); return c }
+
 // Command resolves the commander into a cli.Command instance. This
 // operation is safe to call more options.
 //
@@ -265,10 +337,25 @@ func (c *Commander) Command() *cli.Command {
 	c.once.Do(func() {
 		erc.InvariantOk(c.getContext() != nil, "context must be set when calling command")
 
+		var resolvers []Attachment
+		c.resolvers.With(func(in *dt.List[Attachment]) { resolvers = irt.Collect(in.IteratorFront()) })
+		for _, op := range resolvers {
+			op(c)
+		}
+		c.addShortcutCommands()
+
 		c.cmd.Name = secondValueWhenFirstIsZero(c.cmd.Name, c.name.Get())
 		c.cmd.Usage = secondValueWhenFirstIsZero(c.cmd.Usage, c.usage.Get())
 		c.cmd.EnableShellCompletion = secondValueWhenFirstIsZero(c.cmd.EnableShellCompletion, c.enableShellCompletion.Load())
 		c.cmd.Hidden = c.hidden.Load()
+		c.cmd.Description = secondValueWhenFirstIsZero(c.cmd.Description, c.desc.Get())
+		c.cmd.Category = secondValueWhenFirstIsZero(c.cmd.Category, c.category.Get())
+		c.setHelpMetadata()
+		c.streams.With(func(s *Streams) {
+			c.cmd.Reader = secondValueWhenFirstIsZero(c.cmd.Reader, s.In)
+			c.cmd.Writer = secondValueWhenFirstIsZero(c.cmd.Writer, s.Out)
+			c.cmd.ErrWriter = secondValueWhenFirstIsZero(c.cmd.ErrWriter, s.Err)
+		})
 
 		if len(c.cmd.Aliases) == 0 {
 			var aliases []string
@@ -284,9 +371,15 @@ func (c *Commander) Command() *cli.Command {
 			}
 		})
 
+		chain := c.interceptorChain()
+		if c.action.Get() != nil && len(chain) > 0 {
+			c.cmd.Action = cli.ActionFunc(intercept(Action(c.cmd.Action), chain))
+		}
+
 		c.subcmds.With(func(in *dt.List[*Commander]) {
 			for v := range in.IteratorFront() {
 				v.ctx = c.ctx
+				v.inherited.Set(chain)
 				c.cmd.Commands = append(c.cmd.Commands, v.Command())
 			}
 		})
@@ -301,6 +394,15 @@ type AppOptions struct {
 	Usage   string
 	Name    string
 	Version string
+
+	// CrashReportDir, when set, is the directory where Run writes
+	// crash reports when a hook, middleware, or action
+	// panics. Arguments to flags that are marked secret, or whose
+	// names suggest that they hold credentials, are redacted from
+	// crash reports. Panics in services, which run in their own
+	// goroutines, are reported as errors and do not produce crash
+	// reports.
+	CrashReportDir string
 }
 
 // SetAppOptions set's the commander's options. This is only used by
@@ -331,6 +433,11 @@ func (c *Commander) App() *cli.Command {
 	app.Flags = cmd.Flags
 	app.After = cmd.After
 	app.Before = cmd.Before
+	app.Description = cmd.Description
+	app.Metadata = cmd.Metadata
+	app.Reader = cmd.Reader
+	app.Writer = cmd.Writer
+	app.ErrWriter = cmd.ErrWriter
 
 	return app
 }
@@ -0,0 +1,137 @@
+package cmdr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/ers"
+)
+
+// ErrNotConfirmed is at the root of the errors returned when a
+// destructive command is not confirmed.
+const ErrNotConfirmed = ers.Error("not confirmed")
+
+// ConfirmOptions marks commands as destructive: before the action
+// runs, the command asks the user to confirm, showing a summary of
+// what the command will do. The yes flag, or the environment
+// variable, skips the question.
+//
+// Commands only ask when they are interactive: when the root
+// commander has PromptOptions, commands ask with its prompter (see
+// PrompterFromContext), and otherwise, commands ask when the input
+// of the command (see Streams) is a terminal. When the command is
+// not interactive, and the yes flag and environment variable are
+// not set, the command refuses to run.
+//
+// Use ConfirmOptions with OperationSpec.SetConfirm, to derive the
+// summary from the value that the spec constructs, or as an
+// Attachment, which confirms the actions of the commander and its
+// subcommands, and resolves the value for the summary from the
+// context (see FromContext.)
+type ConfirmOptions[T any] struct {
+	// Question asks the user to confirm. Defaults to "Are you
+	// sure?".
+	Question string
+	// Summary, when set, describes what the command will do,
+	// and is shown before the question.
+	Summary func(T) string
+	// YesFlag is the name of the flag that skips the question.
+	// Defaults to "yes".
+	YesFlag string
+	// EnvVar is the environment variable that, when set to a true
+	// value (e.g. "1" or "true"), skips the question. Defaults to
+	// "<NAME>_YES", where <NAME> is the name of the root command.
+	EnvVar string
+}
+
+// SetConfirm makes the spec's Action destructive: the command asks
+// the user to confirm before the Action runs.
+func (s *OperationSpec[T]) SetConfirm(opts ConfirmOptions[T]) *OperationSpec[T] {
+	s.Confirm = &opts
+	return s
+}
+
+// Destructive asks the user to confirm the actions of the commander
+// and its subcommands, using the default options.
+func Destructive() Attachment { return ConfirmOptions[struct{}]{}.Add }
+
+// Add adds the yes flag to the commander, and asks the user to
+// confirm the actions of the commander and its subcommands. Commands
+// without an action, which print their help, do not ask. Use with
+// the Commander.With method.
+func (opts ConfirmOptions[T]) Add(c *Commander) {
+	c.Flags(opts.flag())
+	c.Interceptors(func(next Action) Action {
+		return func(ctx context.Context, cc *cli.Command) error {
+			var val T
+			if opts.Summary != nil {
+				var ok bool
+				if val, ok = FromContext[T](ctx); !ok {
+					return fmt.Errorf("value to confirm %q (%s): %w", cc.FullName(), DefaultContextKey[T](), ErrNotSet)
+				}
+			}
+
+			if err := opts.confirm(ctx, cc, val); err != nil {
+				return err
+			}
+			return next(ctx, cc)
+		}
+	})
+}
+
+func (opts *ConfirmOptions[T]) flag() Flag {
+	opts.Question = secondValueWhenFirstIsZero(opts.Question, "Are you sure?")
+	opts.YesFlag = secondValueWhenFirstIsZero(opts.YesFlag, "yes")
+
+	return MakeFlag(&FlagOptions[bool]{
+		Name:    opts.YesFlag,
+		Aliases: []string{"y"},
+		Usage:   "do not ask for confirmation",
+	})
+}
+
+// confirm returns nil when the command may run.
+func (opts *ConfirmOptions[T]) confirm(ctx context.Context, cc *cli.Command, val T) error {
+	env := secondValueWhenFirstIsZero(opts.EnvVar, envVarName(cc.Root().Name)+"_YES")
+	if yes, _ := strconv.ParseBool(os.Getenv(env)); yes || cc.Bool(opts.YesFlag) {
+		return nil
+	}
+
+	p, ok := confirmPrompter(ctx)
+	if !ok {
+		return fmt.Errorf("refusing to run %q without --%s when not interactive: %w", cc.FullName(), opts.YesFlag, ErrNotConfirmed)
+	}
+
+	if opts.Summary != nil {
+		fmt.Fprintln(p.out, opts.Summary(val))
+	}
+
+	yes, err := p.Confirm(opts.Question, false)
+	switch {
+	case err != nil:
+		return fmt.Errorf("confirming %q: %w", cc.FullName(), err)
+	case !yes:
+		return fmt.Errorf("%q: %w", cc.FullName(), ErrNotConfirmed)
+	default:
+		return nil
+	}
+}
+
+// confirmPrompter returns the prompter of the command, or, when the
+// root commander does not have PromptOptions, a prompter over the
+// streams of the command, when the input is a terminal.
+func confirmPrompter(ctx context.Context) (*Prompter, bool) {
+	if p, ok := PrompterFromContext(ctx); ok || nonInteractiveCtxKey.Has(ctx) {
+		return p, ok
+	}
+
+	streams := StreamsFromContext(ctx)
+	if _, ok := terminalFd(streams.In); !ok {
+		return nil, false
+	}
+	return NewPrompter(streams.In, streams.Err), true
+}
@@ -0,0 +1,82 @@
+package cmdr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+// openPty opens a pseudo-terminal, and returns the controlling side
+// and the terminal.
+func openPty(t *testing.T) (*os.File, *os.File) {
+	t.Helper()
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skip("pseudo-terminals are not available:", err)
+	}
+	t.Cleanup(func() { ptmx.Close() })
+
+	var unlock int32
+	var num uint32
+	for _, op := range []struct {
+		req uintptr
+		arg unsafe.Pointer
+	}{
+		{syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)},
+		{syscall.TIOCGPTN, unsafe.Pointer(&num)},
+	} {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, ptmx.Fd(), op.req, uintptr(op.arg)); errno != 0 {
+			t.Skip("pseudo-terminals are not available:", errno)
+		}
+	}
+
+	tty, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", num), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skip("pseudo-terminals are not available:", err)
+	}
+	t.Cleanup(func() { tty.Close() })
+	return ptmx, tty
+}
+
+func TestConfirmTerminal(t *testing.T) {
+	ctx := context.Background()
+
+	build := func(tty *os.File, out *bytes.Buffer, ran *bool) *Commander {
+		return MakeRootCommander().SetName("tool").
+			SetReader(tty).
+			SetErrWriter(out).
+			With(Destructive()).
+			SetAction(func(context.Context, *cli.Command) error { *ran = true; return nil })
+	}
+
+	t.Run("WithoutPromptOptions", func(t *testing.T) {
+		ptmx, tty := openPty(t)
+		_, err := ptmx.WriteString("y\n")
+		assert.NotError(t, err)
+
+		var ran bool
+		out := &bytes.Buffer{}
+		assert.NotError(t, Run(ctx, build(tty, out, &ran), []string{"tool"}))
+		check.True(t, ran)
+		check.Substring(t, out.String(), "Are you sure?")
+	})
+	t.Run("NoInput", func(t *testing.T) {
+		_, tty := openPty(t)
+
+		var ran bool
+		out := &bytes.Buffer{}
+		err := Run(ctx, build(tty, out, &ran).With(WithPrompts()), []string{"tool", "--no-input"})
+		assert.ErrorIs(t, err, ErrNotConfirmed)
+		check.True(t, !ran)
+		check.Equal(t, out.String(), "")
+	})
+}
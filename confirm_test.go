@@ -0,0 +1,137 @@
+package cmdr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func TestConfirm(t *testing.T) {
+	ctx := context.Background()
+
+	type target struct{ Name string }
+
+	spec := func(ran *bool) *OperationSpec[*target] {
+		return SpecBuilder(func(_ context.Context, cc *cli.Command) (*target, error) {
+			return &target{Name: cc.String("target")}, nil
+		}).
+			SetConfirm(ConfirmOptions[*target]{
+				Summary: func(t *target) string { return fmt.Sprintf("this deletes %q", t.Name) },
+			}).
+			SetAction(func(context.Context, *target) error { *ran = true; return nil })
+	}
+	build := func(input *string, out io.Writer, ran *bool) *Commander {
+		cmd := MakeRootCommander().SetName("tool").
+			Flags(FlagBuilder("db").SetName("target").Flag()).
+			With(spec(ran).Add)
+		if input != nil {
+			cmd.With(PromptOptions{In: strings.NewReader(*input), Out: out}.Add)
+		} else {
+			cmd.SetReader(strings.NewReader("y\n"))
+		}
+		return cmd
+	}
+	answer := func(s string) *string { return &s }
+
+	t.Run("Confirmed", func(t *testing.T) {
+		var ran bool
+		out := &bytes.Buffer{}
+		assert.NotError(t, Run(ctx, build(answer("y\n"), out, &ran), []string{"tool", "--target", "prod"}))
+		check.True(t, ran)
+		check.Equal(t, out.String(), "this deletes \"prod\"\nAre you sure? [y/N]: ")
+	})
+	t.Run("Declined", func(t *testing.T) {
+		for _, input := range []string{"n\n", "\n"} {
+			var ran bool
+			err := Run(ctx, build(answer(input), io.Discard, &ran), []string{"tool"})
+			assert.ErrorIs(t, err, ErrNotConfirmed)
+			check.True(t, !ran)
+		}
+
+		var ran bool
+		err := Run(ctx, build(answer(""), io.Discard, &ran), []string{"tool"})
+		assert.ErrorIs(t, err, io.EOF)
+		check.True(t, !ran)
+	})
+	t.Run("Yes", func(t *testing.T) {
+		for _, args := range [][]string{{"--yes"}, {"-y"}, {"--no-input", "--yes"}} {
+			var ran bool
+			out := &bytes.Buffer{}
+			assert.NotError(t, Run(ctx, build(answer(""), out, &ran), append([]string{"tool"}, args...)))
+			check.True(t, ran)
+			check.Equal(t, out.String(), "")
+		}
+	})
+	t.Run("EnvVar", func(t *testing.T) {
+		t.Setenv("TOOL_YES", "1")
+		var ran bool
+		assert.NotError(t, Run(ctx, build(nil, io.Discard, &ran), []string{"tool"}))
+		check.True(t, ran)
+
+		t.Setenv("TOOL_YES", "false")
+		ran = false
+		assert.ErrorIs(t, Run(ctx, build(nil, io.Discard, &ran), []string{"tool"}), ErrNotConfirmed)
+		check.True(t, !ran)
+	})
+	t.Run("NotInteractive", func(t *testing.T) {
+		var ran bool
+		err := Run(ctx, build(answer("y\n"), io.Discard, &ran), []string{"tool", "--no-input"})
+		assert.ErrorIs(t, err, ErrNotConfirmed)
+		check.Substring(t, err.Error(), "without --yes")
+		check.True(t, !ran)
+
+		err = Run(ctx, build(nil, io.Discard, &ran), []string{"tool"})
+		assert.ErrorIs(t, err, ErrNotConfirmed)
+		check.True(t, !ran)
+	})
+	t.Run("Attachment", func(t *testing.T) {
+		var ran int
+		build := func(input string, opts ConfirmOptions[*target]) *Commander {
+			sub := MakeCommander().SetName("drop").
+				SetAction(func(context.Context, *cli.Command) error { ran++; return nil })
+			return MakeRootCommander().SetName("tool").
+				With(PromptOptions{In: strings.NewReader(input), Out: io.Discard}.Add).
+				With(opts.Add).
+				Subcommanders(sub)
+		}
+
+		assert.NotError(t, Run(ctx, build("yes\n", ConfirmOptions[*target]{}), []string{"tool", "drop"}))
+		check.Equal(t, ran, 1)
+		assert.ErrorIs(t, Run(ctx, build("no\n", ConfirmOptions[*target]{}), []string{"tool", "drop"}), ErrNotConfirmed)
+		check.Equal(t, ran, 1)
+
+		err := Run(ctx, build("yes\n", ConfirmOptions[*target]{Summary: func(*target) string { return "" }}), []string{"tool", "drop"})
+		assert.ErrorIs(t, err, ErrNotSet)
+		check.Equal(t, ran, 1)
+
+		cmd := MakeRootCommander().SetName("tool").
+			With(Destructive()).
+			SetAction(func(context.Context, *cli.Command) error { ran++; return nil })
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "--yes"}))
+		check.Equal(t, ran, 2)
+	})
+	t.Run("Group", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		cmd := MakeRootCommander().SetName("tool").
+			SetWriter(io.Discard).
+			With(PromptOptions{In: strings.NewReader(""), Out: out}.Add).
+			With(Destructive()).
+			Subcommanders(MakeCommander().SetName("group").Subcommanders(
+				MakeCommander().SetName("drop").SetAction(func(context.Context, *cli.Command) error { return nil }),
+			))
+
+		err := Run(ctx, cmd, []string{"tool", "group"})
+		assert.ErrorIs(t, err, ErrNotSpecified)
+		check.True(t, !errors.Is(err, io.EOF))
+		check.Equal(t, out.String(), "")
+	})
+}
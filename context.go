@@ -0,0 +1,70 @@
+package cmdr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tychoish/fun/erc"
+)
+
+// ContextKey provides a typed key for attaching values to (and
+// retrieving values from) a context. Keys are distinguished both by
+// their type and their name: two keys with the same name but
+// different types do not collide, and the zero value of a
+// ContextKey[T] is the default key used by WithValue, FromContext,
+// and MustFromContext.
+type ContextKey[T any] struct{ name string }
+
+// MakeContextKey constructs a named key for values of type T. Use
+// named keys when you need to store more than one value of the same
+// type on a context.
+func MakeContextKey[T any](name string) ContextKey[T] { return ContextKey[T]{name: name} }
+
+// DefaultContextKey returns the unnamed key for values of type T,
+// which is the key used by WithValue and FromContext.
+func DefaultContextKey[T any]() ContextKey[T] { return ContextKey[T]{} }
+
+// String returns the name of the key and the type of the values
+// stored with it.
+func (k ContextKey[T]) String() string {
+	var zero T
+	return fmt.Sprintf("ContextKey<%T>(%s)", zero, k.name)
+}
+
+// Set attaches the value to the returned context.
+func (k ContextKey[T]) Set(ctx context.Context, val T) context.Context {
+	return context.WithValue(ctx, k, val)
+}
+
+// Get resolves the value from the context, returning false when the
+// value is not set.
+func (k ContextKey[T]) Get(ctx context.Context) (out T, ok bool) {
+	out, ok = ctx.Value(k).(T)
+	return out, ok
+}
+
+// Has returns true when a value for the key is attached to the context.
+func (k ContextKey[T]) Has(ctx context.Context) bool { _, ok := k.Get(ctx); return ok }
+
+// MustGet resolves the value from the context and panics with an
+// invariant violation if it is not set.
+func (k ContextKey[T]) MustGet(ctx context.Context) T {
+	out, ok := k.Get(ctx)
+	erc.InvariantOk(ok, "value for", k, "was not attached to the context")
+	return out
+}
+
+// WithValue attaches a value to the context using the default key
+// for its type.
+func WithValue[T any](ctx context.Context, val T) context.Context {
+	return DefaultContextKey[T]().Set(ctx, val)
+}
+
+// FromContext resolves a value attached with the default key for its
+// type (e.g. using WithValue or OperationSpec.StoreInContext.)
+func FromContext[T any](ctx context.Context) (T, bool) { return DefaultContextKey[T]().Get(ctx) }
+
+// MustFromContext resolves a value attached with the default key for
+// its type, and panics with an invariant violation if the value is
+// not set.
+func MustFromContext[T any](ctx context.Context) T { return DefaultContextKey[T]().MustGet(ctx) }
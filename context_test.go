@@ -0,0 +1,95 @@
+package cmdr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+type testConfig struct {
+	Name string
+}
+
+func TestContextKey(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("Default", func(t *testing.T) {
+		_, ok := FromContext[string](ctx)
+		assert.True(t, !ok)
+
+		vctx := WithValue(ctx, "hello")
+		val, ok := FromContext[string](vctx)
+		assert.True(t, ok)
+		assert.Equal(t, val, "hello")
+		assert.Equal(t, MustFromContext[string](vctx), "hello")
+
+		// other types do not collide
+		_, ok = FromContext[int](vctx)
+		assert.True(t, !ok)
+	})
+	t.Run("Named", func(t *testing.T) {
+		one := MakeContextKey[string]("one")
+		two := MakeContextKey[string]("two")
+
+		vctx := two.Set(one.Set(ctx, "first"), "second")
+		check.Equal(t, one.MustGet(vctx), "first")
+		check.Equal(t, two.MustGet(vctx), "second")
+		check.True(t, !DefaultContextKey[string]().Has(vctx))
+		check.Substring(t, one.String(), "one")
+	})
+	t.Run("MustPanics", func(t *testing.T) {
+		assert.Panic(t, func() { _ = MustFromContext[*testConfig](ctx) })
+	})
+	t.Run("OperationSpec", func(t *testing.T) {
+		count := 0
+		sub := MakeCommander().SetName("sub").
+			Hooks(func(ctx context.Context, cc *cli.Command) error {
+				count++
+				conf, ok := FromContext[*testConfig](ctx)
+				check.True(t, ok)
+				check.Equal(t, conf.Name, "kip")
+				return nil
+			}).
+			SetAction(func(ctx context.Context, cc *cli.Command) error {
+				count++
+				check.Equal(t, MustFromContext[*testConfig](ctx).Name, "kip")
+				return nil
+			})
+
+		cmd := MakeRootCommander().Subcommanders(sub)
+		AddOperationSpec(cmd,
+			SpecBuilder(func(ctx context.Context, cc *cli.Command) (*testConfig, error) {
+				return &testConfig{Name: "kip"}, nil
+			}).StoreInContext().
+				SetMiddleware(func(ctx context.Context, conf *testConfig) context.Context {
+					count++
+					check.Equal(t, MustFromContext[*testConfig](ctx), conf)
+					return ctx
+				}),
+		)
+
+		assert.NotError(t, Run(ctx, cmd, []string{t.Name(), "sub"}))
+		assert.Equal(t, count, 3)
+	})
+	t.Run("OperationSpecNamedKey", func(t *testing.T) {
+		key := MakeContextKey[string]("greeting")
+		cmd := MakeRootCommander()
+		AddOperationSpec(cmd,
+			SpecBuilder(func(ctx context.Context, cc *cli.Command) (string, error) {
+				return "hello", nil
+			}).SetContextKey(key).
+				SetAction(func(ctx context.Context, in string) error {
+					check.Equal(t, key.MustGet(ctx), in)
+					check.True(t, !DefaultContextKey[string]().Has(ctx))
+					return nil
+				}),
+		)
+
+		assert.NotError(t, Run(ctx, cmd, []string{t.Name()}))
+	})
+}
@@ -0,0 +1,197 @@
+package cmdr
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/dt"
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/ers"
+)
+
+// ErrCrashed is at the root of the CrashError returned by Run when a
+// hook, middleware, or action panics.
+const ErrCrashed = ers.Error("command crashed")
+
+const redacted = "<redacted>"
+
+// secretFlagMarkers are substrings of flag names whose values are
+// redacted from crash reports, even when the flags are not marked
+// as secret.
+var secretFlagMarkers = []string{"password", "passwd", "secret", "token", "credential", "apikey", "api-key", "private-key"}
+
+// CrashError is returned by Run when a hook, middleware, or action
+// panics. The CrashError is rooted in both ErrCrashed and
+// ers.ErrRecoveredPanic.
+type CrashError struct {
+	// Command is the full path of the command that crashed
+	// (e.g. "tool deploy").
+	Command string
+	// Args are the arguments, with the values of secret flags
+	// redacted.
+	Args []string
+	// Stack is the stack trace of the panic.
+	Stack []byte
+	// ReportPath is the path of the crash report, and is empty
+	// when the crash report was not written.
+	ReportPath string
+	// Err is the panic, converted to an error.
+	Err error
+}
+
+func (e *CrashError) Error() string {
+	if e.ReportPath == "" {
+		return fmt.Sprintf("%s: %q: %v", ErrCrashed, e.Command, e.Err)
+	}
+	return fmt.Sprintf("%s: %q: %v (report: %s)", ErrCrashed, e.Command, e.Err, e.ReportPath)
+}
+
+func (e *CrashError) Unwrap() error      { return e.Err }
+func (*CrashError) Is(target error) bool { return target == ErrCrashed }
+
+// report renders the crash report.
+func (e *CrashError) report() []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "command:", e.Command)
+	fmt.Fprintln(buf, "args:", strings.Join(e.Args, " "))
+	fmt.Fprintln(buf, "time:", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintln(buf, "go:", runtime.Version(), runtime.GOOS+"/"+runtime.GOARCH)
+	fmt.Fprintln(buf, "pid:", os.Getpid())
+	fmt.Fprintln(buf, "panic:", e.Err)
+	fmt.Fprintln(buf)
+	buf.Write(e.Stack)
+	return buf.Bytes()
+}
+
+// write writes the crash report to a new file in the directory.
+func (e *CrashError) write(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("crash-%s-%s-%d.txt",
+		strings.ReplaceAll(e.Command, " ", "-"),
+		time.Now().UTC().Format("20060102T150405"),
+		os.Getpid(),
+	)
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, e.report(), 0o600); err != nil {
+		return err
+	}
+
+	e.ReportPath = path
+	return nil
+}
+
+// recoverCrash converts a recovered panic value into a CrashError,
+// writing a crash report if a crash report directory is configured.
+func (c *Commander) recoverCrash(r any, app *cli.Command, args []string) error {
+	crash := &CrashError{
+		Command: commandPath(app, args),
+		Args:    redactArgs(args, c.flagNames(isSecretFlag), c.flagNames(isSwitchFlag)),
+		Stack:   debug.Stack(),
+		Err:     erc.ParsePanic(r),
+	}
+
+	if dir := c.opts.Get().CrashReportDir; dir != "" {
+		if err := crash.write(dir); err != nil {
+			return erc.Join(crash, fmt.Errorf("writing crash report: %w", err))
+		}
+	}
+
+	return crash
+}
+
+// commandPath resolves the full path of the command selected by the
+// arguments.
+func commandPath(app *cli.Command, args []string) string {
+	path := []string{app.Name}
+	for cmd := app; len(args) > 1; {
+		idx := firstPositionalArg(cmd, args)
+		if idx < 0 {
+			break
+		}
+
+		sub := cmd.Command(args[idx])
+		if sub == nil {
+			break
+		}
+
+		path = append(path, sub.Name)
+		cmd, args = sub, args[idx:]
+	}
+	return strings.Join(path, " ")
+}
+
+// flagNames collects the names of the flags on the commander and
+// all of its subcommanders that match the predicate.
+func (c *Commander) flagNames(include func(Flag) bool) *dt.Set[string] {
+	out := &dt.Set[string]{}
+	c.flags.With(func(in *dt.List[Flag]) {
+		for flag := range in.IteratorFront() {
+			if flag.value != nil && include(flag) {
+				for _, name := range flag.value.Names() {
+					out.Add(name)
+				}
+			}
+		}
+	})
+	c.subcmds.With(func(in *dt.List[*Commander]) {
+		for sub := range in.IteratorFront() {
+			out.Extend(sub.flagNames(include).Iterator())
+		}
+	})
+	return out
+}
+
+func isSecretFlag(flag Flag) bool { return flag.secret }
+
+// isSwitchFlag reports whether the flag is boolean, and so never
+// consumes the following argument as its value.
+func isSwitchFlag(flag Flag) bool {
+	df, ok := flag.value.(cli.DocGenerationFlag)
+	return ok && !df.TakesValue()
+}
+
+// redactArgs replaces the values of secret flags in the
+// arguments. The argument after a secret flag is its value, even
+// when it starts with a dash, unless the flag is a switch, which does
+// not take a value.
+func redactArgs(args []string, secrets, switches *dt.Set[string]) []string {
+	isSecret := func(name string) bool {
+		lower := strings.ToLower(name)
+		return secrets.Check(name) || slices.ContainsFunc(secretFlagMarkers, func(m string) bool { return strings.Contains(lower, m) })
+	}
+
+	out := slices.Clone(args)
+	for idx := 1; idx < len(out); idx++ {
+		arg := out[idx]
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		switch {
+		case !isSecret(name):
+		case hasValue:
+			out[idx] = arg[:strings.Index(arg, "=")+1] + redacted
+		case !switches.Check(name) && idx+1 < len(out) && out[idx+1] != "--":
+			idx++
+			out[idx] = redacted
+		}
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+	"github.com/tychoish/fun/dt"
+	"github.com/tychoish/fun/ers"
+	"github.com/tychoish/fun/srv"
+)
+
+func TestCrash(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	build := func(cleanup *bool, phase string) *Commander {
+		sub := MakeCommander().SetName("sub").
+			Flags(MakeFlag(&FlagOptions[string]{Name: "auth", Secret: true})).
+			Hooks(func(context.Context, *cli.Command) error {
+				if phase == "hook" {
+					panic("hook crashed")
+				}
+				return nil
+			}).
+			SetAction(func(ctx context.Context, cc *cli.Command) error {
+				srv.AddCleanup(ctx, func(context.Context) error { *cleanup = true; return nil })
+				panic(errors.New("action crashed"))
+			})
+
+		return MakeRootCommander().SetName("tool").
+			Flags(
+				MakeFlag(&FlagOptions[string]{Name: "api-token"}),
+				MakeFlag(&FlagOptions[bool]{Name: "token-refresh"}),
+			).
+			Subcommanders(sub)
+	}
+
+	t.Run("Action", func(t *testing.T) {
+		var cleanup bool
+		dir := t.TempDir()
+		cmd := build(&cleanup, "action").SetAppOptions(AppOptions{CrashReportDir: dir})
+
+		err := Run(ctx, cmd, []string{"tool", "--api-token", "hunter2", "--token-refresh", "sub", "--auth=hunter3", "arg"})
+		assert.ErrorIs(t, err, ErrCrashed)
+		assert.ErrorIs(t, err, ers.ErrRecoveredPanic)
+		assert.True(t, cleanup)
+
+		var crash *CrashError
+		assert.True(t, errors.As(err, &crash))
+		check.Equal(t, crash.Command, "tool sub")
+		check.EqualItems(t, crash.Args, []string{"tool", "--api-token", redacted, "--token-refresh", "sub", "--auth=" + redacted, "arg"})
+		assert.True(t, crash.ReportPath != "")
+
+		data, err := os.ReadFile(crash.ReportPath)
+		assert.NotError(t, err)
+		report := string(data)
+		check.Substring(t, report, "command: tool sub")
+		check.Substring(t, report, "action crashed")
+		check.Substring(t, report, "go: go")
+		check.True(t, !strings.Contains(report, "hunter"))
+	})
+	t.Run("Hook", func(t *testing.T) {
+		var cleanup bool
+		err := Run(ctx, build(&cleanup, "hook"), []string{"tool", "sub"})
+		assert.ErrorIs(t, err, ErrCrashed)
+		check.Substring(t, err.Error(), "hook crashed")
+		check.True(t, !cleanup)
+
+		var crash *CrashError
+		assert.True(t, errors.As(err, &crash))
+		check.Equal(t, crash.ReportPath, "")
+	})
+	t.Run("Redact", func(t *testing.T) {
+		secrets := &dt.Set[string]{}
+		secrets.Add("key")
+		switches := &dt.Set[string]{}
+		switches.Add("token-refresh")
+		check.EqualItems(t,
+			redactArgs([]string{"tool", "--key", "one", "--password=two", "--name", "three", "--", "--token", "four"}, secrets, switches),
+			[]string{"tool", "--key", redacted, "--password=" + redacted, "--name", "three", "--", "--token", "four"},
+		)
+		check.EqualItems(t,
+			redactArgs([]string{"tool", "--token-refresh", "deploy", "--token-refresh=false", "--token", "five"}, secrets, switches),
+			[]string{"tool", "--token-refresh", "deploy", "--token-refresh=" + redacted, "--token", redacted},
+		)
+		check.EqualItems(t,
+			redactArgs([]string{"tool", "--password", "-hunter2", "--token", "-x", "--key", "--", "--key", "six"}, secrets, switches),
+			[]string{"tool", "--password", redacted, "--token", redacted, "--key", "--", "--key", "six"},
+		)
+	})
+}
@@ -0,0 +1,169 @@
+package cmdr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tychoish/fun/ers"
+)
+
+// ErrInvalidSchedule is at the root of the errors returned for
+// invalid cron expressions and schedule flags.
+const ErrInvalidSchedule = ers.Error("invalid schedule")
+
+// cronSchedule is a parsed five-field cron expression (minute, hour,
+// day of month, month, day of week), with a bit set for each allowed
+// value of each field.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record unrestricted day fields (those
+	// that begin with "*", including steps like "*/2"): when
+	// both day fields are restricted, a day matches if either
+	// field matches.
+	domStar, dowStar bool
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cronMonths = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	cronDays   = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// parseCron parses a standard cron expression: five fields of
+// numbers, names (for months and days of the week), ranges ("1-5"),
+// lists ("1,15"), steps ("*/15", "0-30/5"), and "*"; or one of the
+// descriptors @yearly, @annually, @monthly, @weekly, @daily,
+// @midnight, and @hourly. Both 0 and 7 are Sunday.
+func parseCron(expr string) (*cronSchedule, error) {
+	spec := strings.TrimSpace(expr)
+	if desc, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = desc
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q has %d fields, not 5: %w", expr, len(fields), ErrInvalidSchedule)
+	}
+
+	out := &cronSchedule{domStar: strings.HasPrefix(fields[2], "*"), dowStar: strings.HasPrefix(fields[4], "*")}
+	var err error
+	for _, f := range []struct {
+		dst      *uint64
+		min, max int
+		names    map[string]int
+	}{
+		{&out.minute, 0, 59, nil},
+		{&out.hour, 0, 23, nil},
+		{&out.dom, 1, 31, nil},
+		{&out.month, 1, 12, cronMonths},
+		{&out.dow, 0, 7, cronDays},
+	} {
+		if *f.dst, err = parseCronField(fields[0], f.min, f.max, f.names); err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+		fields = fields[1:]
+	}
+
+	if out.dow&(1<<7) != 0 {
+		out.dow |= 1
+	}
+	return out, nil
+}
+
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	value := func(in string) (int, error) {
+		if n, ok := names[strings.ToLower(in)]; ok {
+			return n, nil
+		}
+		n, err := strconv.Atoi(in)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("value %q is not between %d and %d: %w", in, min, max, ErrInvalidSchedule)
+		}
+		return n, nil
+	}
+
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("step %q: %w", stepStr, ErrInvalidSchedule)
+			}
+		}
+
+		lo, hi := min, max
+		switch startStr, endStr, isRange := strings.Cut(rng, "-"); {
+		case rng == "*":
+		case isRange:
+			var err error
+			if lo, err = value(startStr); err != nil {
+				return 0, err
+			}
+			if hi, err = value(endStr); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("range %q: %w", rng, ErrInvalidSchedule)
+			}
+		default:
+			var err error
+			if lo, err = value(rng); err != nil {
+				return 0, err
+			}
+			if !hasStep {
+				hi = lo
+			}
+		}
+
+		for n := lo; n <= hi; n += step {
+			bits |= 1 << n
+		}
+	}
+	return bits, nil
+}
+
+// next returns the first time, after the provided time, that matches
+// the schedule, or the zero time if no time in the next five years
+// matches (e.g. "0 0 30 2 *").
+func (s *cronSchedule) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
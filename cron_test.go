@@ -0,0 +1,70 @@
+package cmdr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func TestCron(t *testing.T) {
+	at := func(s string) time.Time {
+		out, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		assert.NotError(t, err)
+		return out
+	}
+
+	t.Run("Next", func(t *testing.T) {
+		for _, tc := range []struct {
+			expr, after, next string
+		}{
+			{"* * * * *", "2025-03-07 10:07", "2025-03-07 10:08"},
+			{"*/15 * * * *", "2025-03-07 10:07", "2025-03-07 10:15"},
+			{"0,30 * * * *", "2025-03-07 10:30", "2025-03-07 11:00"},
+			{"5-10/5 3 * * *", "2025-03-07 03:06", "2025-03-07 03:10"},
+			{"0 9 * * mon-fri", "2025-03-08 12:00", "2025-03-10 09:00"},
+			{"0 0 * * 7", "2025-03-07 00:00", "2025-03-09 00:00"},
+			{"0 0 1 jan *", "2025-03-07 00:00", "2026-01-01 00:00"},
+			{"0 0 1 * 1", "2025-03-07 00:00", "2025-03-10 00:00"},
+			{"0 0 */2 * 1", "2025-03-07 00:00", "2025-03-17 00:00"},
+			{"0 0 1 * */2", "2025-03-07 00:00", "2025-04-01 00:00"},
+			{"0 0 29 2 *", "2025-03-07 00:00", "2028-02-29 00:00"},
+			{"@daily", "2025-03-07 10:07", "2025-03-08 00:00"},
+			{"@hourly", "2025-03-07 10:07", "2025-03-07 11:00"},
+			{"@WEEKLY", "2025-03-07 10:07", "2025-03-09 00:00"},
+		} {
+			t.Run(tc.expr, func(t *testing.T) {
+				sched, err := parseCron(tc.expr)
+				assert.NotError(t, err)
+				check.Equal(t, sched.next(at(tc.after)), at(tc.next))
+			})
+		}
+	})
+	t.Run("Never", func(t *testing.T) {
+		sched, err := parseCron("0 0 30 2 *")
+		assert.NotError(t, err)
+		check.True(t, sched.next(time.Now()).IsZero())
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		for _, expr := range []string{
+			"",
+			"* * * *",
+			"* * * * * *",
+			"60 * * * *",
+			"* 24 * * *",
+			"* * 0 * *",
+			"* * * 13 *",
+			"* * * * 8",
+			"*/0 * * * *",
+			"10-5 * * * *",
+			"a * * * *",
+			"@sometimes",
+		} {
+			t.Run(expr, func(t *testing.T) {
+				_, err := parseCron(expr)
+				assert.ErrorIs(t, err, ErrInvalidSchedule)
+			})
+		}
+	})
+}
@@ -0,0 +1,355 @@
+package cmdr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/ers"
+	"github.com/tychoish/fun/srv"
+)
+
+// ErrNotRunning is at the root of the errors returned by the status
+// and stop subcommands of DaemonOptions when the daemon is not
+// running.
+const ErrNotRunning = ers.Error("daemon is not running")
+
+// errDaemonStarted stops the parent process after it starts the
+// daemon; Run returns nil when the command returns this error.
+const errDaemonStarted = ers.Error("daemon started")
+
+var runArgsCtxKey = MakeContextKey[[]string]("cmdr.run-args")
+
+// DaemonOptions makes it possible to run a (typically blocking) root
+// command as a daemon. When the daemon flag is set, the command
+// re-executes its binary, with the same arguments, detached from the
+// terminal (in a new session), with standard input from the null
+// device and standard output and error redirected to the log file
+// (or the null device). The command returns once the daemon has
+// started.
+//
+// The daemon writes its PID to the pidfile, which it holds an
+// advisory lock on (see LockOptions) while it runs, so that a second
+// daemon cannot start, and removes the pidfile when it returns. The
+// options also add "status" and "stop" subcommands, which read the
+// pidfile: stop sends the daemon SIGTERM, which triggers the srv
+// shutdown signal of the daemon, canceling the context of its action
+// and services so that the cleanup runs, and waits for the daemon to
+// return. When the daemon is not running, both subcommands return an
+// ExitError, rooted in ErrNotRunning, with code 3.
+//
+// Daemonization is not supported on all platforms: where file locks
+// are not supported, the daemon fails to start, and where signals are
+// not supported (e.g. windows), stop returns an error.
+type DaemonOptions struct {
+	// DaemonFlag is the name of the flag that runs the command
+	// as a daemon. Defaults to "daemon".
+	DaemonFlag string
+	// PIDFileFlag is the name of the flag that specifies the
+	// pidfile. Defaults to "pidfile".
+	PIDFileFlag string
+	// LogFileFlag is the name of the flag that specifies the
+	// file that receives the output of the daemon. Defaults to
+	// "log-file".
+	LogFileFlag string
+	// PIDFile is the default pidfile. Defaults to "<name>.pid",
+	// in os.TempDir(), where <name> is the name of the command.
+	PIDFile string
+	// Executable is the binary that runs the daemon. Defaults to
+	// the executable of the current process.
+	Executable string
+	// EnvVar is the environment variable that marks the daemon
+	// process. Defaults to "<NAME>_DAEMON", where <NAME> is the
+	// name of the command.
+	EnvVar string
+	// StartTimeout is how long to wait for the daemon to start.
+	// Defaults to 10 seconds.
+	StartTimeout time.Duration
+	// StopTimeout is how long the stop subcommand waits for the
+	// daemon to return. Defaults to 30 seconds.
+	StopTimeout time.Duration
+}
+
+// Daemon adds the default daemon flags and subcommands to the
+// commander.
+func Daemon() Attachment { return DaemonOptions{}.Add }
+
+// Add attaches the daemon flags and subcommands to the root
+// commander. Use with the Commander.With method.
+func (opts DaemonOptions) Add(c *Commander) {
+	opts.DaemonFlag = secondValueWhenFirstIsZero(opts.DaemonFlag, "daemon")
+	opts.PIDFileFlag = secondValueWhenFirstIsZero(opts.PIDFileFlag, "pidfile")
+	opts.LogFileFlag = secondValueWhenFirstIsZero(opts.LogFileFlag, "log-file")
+	opts.StartTimeout = secondValueWhenFirstIsZero(opts.StartTimeout, 10*time.Second)
+	opts.StopTimeout = secondValueWhenFirstIsZero(opts.StopTimeout, 30*time.Second)
+
+	c.Flags(
+		MakeFlag(&FlagOptions[bool]{
+			Name:  opts.DaemonFlag,
+			Usage: "run the command as a daemon, in the background",
+		}),
+		MakeFlag(&FlagOptions[string]{
+			Name:      opts.PIDFileFlag,
+			Usage:     "path of the daemon's pidfile",
+			Default:   opts.PIDFile,
+			TakesFile: true,
+		}),
+		MakeFlag(&FlagOptions[string]{
+			Name:      opts.LogFileFlag,
+			Usage:     "append the daemon's output to this file",
+			TakesFile: true,
+		}),
+	)
+
+	// the parent process starts the daemon before any of the
+	// hooks or middleware run.
+	before := c.cmd.Before
+	c.cmd.Before = func(ctx context.Context, cc *cli.Command) (context.Context, error) {
+		if !cc.Bool(opts.DaemonFlag) || opts.isDaemon(cc) {
+			return before(ctx, cc)
+		}
+
+		pid, err := opts.start(c.getContext(), cc)
+		if err != nil {
+			return ctx, err
+		}
+
+		fmt.Fprintf(cc.Root().Writer, "started %s (pid %d)\n", cc.Name, pid)
+		return ctx, errDaemonStarted
+	}
+
+	// the daemon handles SIGTERM before it locks the pidfile,
+	// because the parent and stop treat the lock as the daemon
+	// running.
+	c.AddMiddleware(MiddlewareOptions{
+		Name:     "daemon signals",
+		Priority: math.MinInt,
+		Before:   []string{"daemon lock"},
+		Middleware: func(ctx context.Context, cc *cli.Command) (context.Context, error) {
+			if !opts.isDaemon(cc) {
+				return ctx, nil
+			}
+			return ctx, shutdownOnTermination(ctx)
+		},
+	})
+
+	c.holdLock("daemon lock", func(ctx context.Context, cc *cli.Command) (*fileLock, error) {
+		if !opts.isDaemon(cc) {
+			return nil, nil
+		}
+		path := opts.pidfile(cc)
+		return acquireLock(ctx, path, LockOptions{PIDFile: path})
+	})
+
+	c.Subcommanders(
+		MakeCommander().SetName("status").
+			SetUsage("report whether the daemon is running").
+			SetAction(func(_ context.Context, cc *cli.Command) error {
+				pid, err := opts.running(cc)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cc.Root().Writer, "%s is running (pid %d)\n", cc.Root().Name, pid)
+				return nil
+			}),
+		MakeCommander().SetName("stop").
+			SetUsage("stop the daemon").
+			SetAction(func(ctx context.Context, cc *cli.Command) error {
+				pid, err := opts.running(cc)
+				if err != nil {
+					return err
+				}
+				if err := opts.stop(ctx, cc, pid); err != nil {
+					return err
+				}
+				fmt.Fprintf(cc.Root().Writer, "stopped %s (pid %d)\n", cc.Root().Name, pid)
+				return nil
+			}),
+	)
+}
+
+func (opts DaemonOptions) envVar(cc *cli.Command) string {
+	return secondValueWhenFirstIsZero(opts.EnvVar, envVarName(cc.Root().Name)+"_DAEMON")
+}
+
+func (opts DaemonOptions) isDaemon(cc *cli.Command) bool { return os.Getenv(opts.envVar(cc)) != "" }
+
+func (opts DaemonOptions) pidfile(cc *cli.Command) string {
+	if path := cc.String(opts.PIDFileFlag); path != "" {
+		return path
+	}
+	return filepath.Join(os.TempDir(), cc.Root().Name+".pid")
+}
+
+// shutdownOnTermination starts a service, managed by the
+// orchestrator, that triggers the shutdown signal of the command when
+// the process receives a termination signal.
+func shutdownOnTermination(ctx context.Context) error {
+	if len(terminationSignals) == 0 || !srv.HasOrchestrator(ctx) {
+		return nil
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, terminationSignals...)
+
+	err := srv.GetOrchestrator(ctx).Add(&srv.Service{
+		Name: "daemon signals",
+		Run: func(sctx context.Context) error {
+			defer signal.Stop(ch)
+			select {
+			case <-sctx.Done():
+			case <-ch:
+				srv.GetShutdownSignal(ctx)()
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		signal.Stop(ch)
+	}
+	return err
+}
+
+// start re-executes the command as a daemon, and waits for the
+// daemon to lock its pidfile.
+func (opts DaemonOptions) start(ctx context.Context, cc *cli.Command) (int, error) {
+	args, ok := runArgsCtxKey.Get(ctx)
+	if !ok || len(args) == 0 {
+		return 0, fmt.Errorf("arguments for daemon: %w", ErrNotDefined)
+	}
+
+	exe := opts.Executable
+	if exe == "" {
+		var err error
+		if exe, err = os.Executable(); err != nil {
+			return 0, fmt.Errorf("starting daemon: %w", err)
+		}
+	}
+
+	pidfile := opts.pidfile(cc)
+	if pid, running, err := lockStatus(pidfile); err != nil {
+		return 0, err
+	} else if running {
+		return 0, fmt.Errorf("daemon pid %d: %w", pid, ErrLocked)
+	}
+
+	cmd := exec.Command(exe, args[1:]...)
+	cmd.Env = append(os.Environ(), opts.envVar(cc)+"=1")
+	detach(cmd)
+
+	if path := cc.String(opts.LogFileFlag); path != "" {
+		logfile, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return 0, fmt.Errorf("opening log file: %w", err)
+		}
+		defer logfile.Close()
+		cmd.Stdout = logfile
+		cmd.Stderr = logfile
+	}
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("starting daemon: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	timeout := time.NewTimer(opts.StartTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-exited:
+			return 0, fmt.Errorf("daemon exited during startup (see %s): %w", secondValueWhenFirstIsZero(cc.String(opts.LogFileFlag), "the log file"), erc.Join(err, ErrNotRunning))
+		case <-timeout.C:
+			return 0, fmt.Errorf("daemon pid %d did not start within %s: %w", cmd.Process.Pid, opts.StartTimeout, ErrNotRunning)
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-ticker.C:
+			if pid, running, _ := lockStatus(pidfile); running && pid == cmd.Process.Pid {
+				return pid, nil
+			}
+		}
+	}
+}
+
+// running returns the PID of the daemon, and an ExitError when the
+// daemon is not running.
+func (opts DaemonOptions) running(cc *cli.Command) (int, error) {
+	pid, running, err := lockStatus(opts.pidfile(cc))
+	if err != nil {
+		return 0, err
+	}
+	if !running {
+		return 0, &ExitError{Command: cc.FullName(), Code: 3, Err: ErrNotRunning}
+	}
+	return pid, nil
+}
+
+// stop sends SIGTERM to the daemon and waits for it to release its
+// pidfile.
+func (opts DaemonOptions) stop(ctx context.Context, cc *cli.Command, pid int) error {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if err := terminate(proc); err != nil {
+		return fmt.Errorf("stopping daemon pid %d: %w", pid, err)
+	}
+
+	timeout := time.NewTimer(opts.StopTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-timeout.C:
+			return fmt.Errorf("daemon pid %d did not stop within %s", pid, opts.StopTimeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, running, err := lockStatus(opts.pidfile(cc)); err != nil || !running {
+				return err
+			}
+		}
+	}
+}
+
+// lockStatus reports whether a process holds the lock on the file,
+// and the PID that the file contains.
+func lockStatus(path string) (pid int, running bool, err error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	defer file.Close()
+
+	data := make([]byte, 32)
+	n, _ := file.ReadAt(data, 0)
+	pid, _ = strconv.Atoi(string(bytes.TrimSpace(data[:n])))
+
+	switch err := tryLock(file); {
+	case err == nil:
+		return pid, false, unlock(file)
+	case errors.Is(err, errWouldBlock):
+		return pid, true, nil
+	default:
+		return pid, false, fmt.Errorf("checking lock %q: %w", path, err)
+	}
+}
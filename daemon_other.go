@@ -0,0 +1,21 @@
+//go:build !unix
+
+package cmdr
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+var terminationSignals []os.Signal
+
+func detach(*exec.Cmd) {}
+
+// terminate cannot stop daemons on platforms without signals: the
+// processes do not have a graceful shutdown to trigger.
+func terminate(*os.Process) error {
+	return fmt.Errorf("signaling daemons is not supported on %s: %w", runtime.GOOS, errors.ErrUnsupported)
+}
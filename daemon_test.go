@@ -0,0 +1,124 @@
+//go:build unix
+
+package cmdr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func daemonTestCommander(exe string, out *bytes.Buffer) *Commander {
+	cmd := MakeRootCommander().SetName("tool").
+		With(DaemonOptions{Executable: exe, EnvVar: "CMDR_TEST_DAEMONIZED", StopTimeout: 10 * time.Second}.Add).
+		SetAction(func(ctx context.Context, _ *cli.Command) error {
+			fmt.Println("daemon running")
+			<-ctx.Done()
+			fmt.Println("daemon stopping")
+			return nil
+		})
+	cmd.cmd.Writer = out
+	return cmd
+}
+
+// TestDaemonHelperProcess runs the daemon started by TestDaemon.
+func TestDaemonHelperProcess(t *testing.T) {
+	if os.Getenv("CMDR_TEST_DAEMON_HELPER") == "" {
+		t.Skip("only runs as the daemon of TestDaemon")
+	}
+
+	args := os.Args[slices.Index(os.Args, "--")+1:]
+	assert.NotError(t, Run(context.Background(), daemonTestCommander("", &bytes.Buffer{}), append([]string{"tool"}, args...)))
+}
+
+func TestDaemon(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	testBinary, err := os.Executable()
+	assert.NotError(t, err)
+
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "daemon.sh")
+	assert.NotError(t, os.WriteFile(exe, []byte(fmt.Sprintf("#!/bin/sh\nexec %q -test.run='^TestDaemonHelperProcess$' -- \"$@\"\n", testBinary)), 0o755))
+	pidfile := filepath.Join(dir, "tool.pid")
+	logfile := filepath.Join(dir, "tool.log")
+
+	t.Setenv("CMDR_TEST_DAEMON_HELPER", "1")
+
+	run := func(args ...string) (string, error) {
+		out := &bytes.Buffer{}
+		err := Run(ctx, daemonTestCommander(exe, out), append([]string{"tool"}, args...))
+		return out.String(), err
+	}
+
+	t.Run("NotRunning", func(t *testing.T) {
+		_, err := run("--pidfile", pidfile, "status")
+		assert.ErrorIs(t, err, ErrNotRunning)
+		ee, ok := err.(*ExitError)
+		assert.True(t, ok)
+		check.Equal(t, ee.Code, 3)
+
+		_, err = run("--pidfile", pidfile, "stop")
+		assert.ErrorIs(t, err, ErrNotRunning)
+	})
+	t.Run("Lifecycle", func(t *testing.T) {
+		out, err := run("--daemon", "--pidfile", pidfile, "--log-file", logfile)
+		assert.NotError(t, err)
+		check.Substring(t, out, "started tool (pid ")
+
+		data, err := os.ReadFile(pidfile)
+		assert.NotError(t, err)
+		pid, err := strconv.Atoi(string(bytes.TrimSpace(data)))
+		assert.NotError(t, err)
+		check.NotEqual(t, pid, os.Getpid())
+
+		out, err = run("--pidfile", pidfile, "status")
+		assert.NotError(t, err)
+		check.Equal(t, out, fmt.Sprintf("tool is running (pid %d)\n", pid))
+
+		_, err = run("--daemon", "--pidfile", pidfile)
+		assert.ErrorIs(t, err, ErrLocked)
+
+		out, err = run("--pidfile", pidfile, "stop")
+		assert.NotError(t, err)
+		check.Equal(t, out, fmt.Sprintf("stopped tool (pid %d)\n", pid))
+
+		_, err = os.Stat(pidfile)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		_, err = run("--pidfile", pidfile, "status")
+		assert.ErrorIs(t, err, ErrNotRunning)
+
+		logs, err := os.ReadFile(logfile)
+		assert.NotError(t, err)
+		check.Substring(t, string(logs), "daemon running")
+		check.Substring(t, string(logs), "daemon stopping")
+	})
+	t.Run("StartupFailure", func(t *testing.T) {
+		failing := filepath.Join(dir, "fail.sh")
+		assert.NotError(t, os.WriteFile(failing, []byte("#!/bin/sh\necho broken\nexit 1\n"), 0o755))
+
+		err := Run(ctx, MakeRootCommander().SetName("tool").
+			With(DaemonOptions{Executable: failing, EnvVar: "CMDR_TEST_DAEMONIZED"}.Add).
+			SetAction(func(context.Context, *cli.Command) error { return nil }),
+			[]string{"tool", "--daemon", "--pidfile", pidfile, "--log-file", logfile})
+		assert.ErrorIs(t, err, ErrNotRunning)
+		check.Substring(t, err.Error(), "exited during startup")
+
+		logs, err := os.ReadFile(logfile)
+		assert.NotError(t, err)
+		check.Substring(t, string(logs), "broken")
+	})
+}
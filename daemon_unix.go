@@ -0,0 +1,20 @@
+//go:build unix
+
+package cmdr
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// terminationSignals shut the daemon down gracefully; stop sends
+// them with terminate.
+var terminationSignals = []os.Signal{syscall.SIGTERM}
+
+// detach starts the command in a new session, without a controlling
+// terminal.
+func detach(cmd *exec.Cmd) { cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true} }
+
+// terminate asks the daemon to shut down gracefully.
+func terminate(proc *os.Process) error { return proc.Signal(syscall.SIGTERM) }
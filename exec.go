@@ -2,10 +2,12 @@ package cmdr
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os"
 
 	"github.com/tychoish/fun/adt"
+	"github.com/tychoish/fun/dt"
 	"github.com/tychoish/fun/erc"
 	"github.com/tychoish/fun/ers"
 	"github.com/tychoish/fun/srv"
@@ -18,16 +20,45 @@ const ErrNotSpecified = ers.Error("not specified")
 const ErrNotSet = ers.Error("not set")
 
 // Run executes a commander with the specified command line arguments.
-func Run(ctx context.Context, c *Commander, args []string) error {
+//
+// Run recovers panics in hooks, middleware, and actions: after a
+// panic, Run still shuts down the services and runs the cleanup
+// functions registered with the srv package, writes a crash report
+// if the AppOptions.CrashReportDir is set, and returns a *CrashError.
+// Services run in their own goroutines, so Run does not recover
+// panics in services: the srv package and ServiceOptions convert
+// these panics to errors, and they do not produce crash reports.
+func Run(ctx context.Context, c *Commander, args []string) (err error) {
 	if c.ctx == nil {
 		c.ctx = adt.NewAtomic(ctxMaker(ctx))
 	}
 
 	c.setContext(ctx)
 	app := c.App()
-	err := app.Run(c.getContext(), args)
+	defer installHelpPrinter(app)()
+
+	args, err = c.expandShortcuts(app, args)
+	if err != nil {
+		return err
+	}
+	c.setContext(runArgsCtxKey.Set(c.getContext(), args))
+
+	err = func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = c.recoverCrash(r, app, args)
+			}
+		}()
+		return app.Run(c.getContext(), args)
+	}()
+	if errors.Is(err, errDaemonStarted) {
+		err = nil
+	}
 
 	cctx := c.getContext()
+	if cctx == nil {
+		return err
+	}
 	if srv.HasShutdownSignal(cctx) {
 		srv.GetShutdownSignal(cctx)()
 	}
@@ -35,14 +66,27 @@ func Run(ctx context.Context, c *Commander, args []string) error {
 		err = erc.Join(err, srv.GetOrchestrator(cctx).Wait())
 	}
 
+	command := commandPath(app, args)
+	c.completers.With(func(in *dt.List[completer]) {
+		for op := range in.IteratorFront() {
+			op(cctx, command, err)
+		}
+	})
+
 	return err
 }
 
 // Main provides an alternative to Run() for calling within in a
 // program's main() function. Non-nil errors are logged at the
-// "Emergency" level and os.Exit(1) is called.
+// "Emergency" level and os.Exit(1) is called. When the error is (or
+// wraps) an ExitError, as returned by external commands, the process
+// exits with the ExitError's code instead.
 func Main(ctx context.Context, c *Commander) {
 	if err := Run(ctx, c, os.Args); err != nil {
+		var ee *ExitError
+		if errors.As(err, &ee) {
+			os.Exit(ee.Code)
+		}
 		log.Panic(err)
 	}
 }
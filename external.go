@@ -0,0 +1,205 @@
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/dt"
+	"github.com/tychoish/fun/irt"
+)
+
+// ExitError is returned by commands that run external processes
+// when the process exits with a non-zero exit code. Main exits with
+// the process' exit code when the command returns an ExitError.
+type ExitError struct {
+	Command string
+	Code    int
+	Err     error
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("command %q exited with code %d: %v", e.Command, e.Code, e.Err)
+}
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// ExternalCommandOptions configures the discovery of external
+// ("plugin") subcommands from the PATH, following the convention of
+// git and kubectl: when the root command is named "mytool", an
+// executable named "mytool-foo" is available as "mytool foo".
+type ExternalCommandOptions struct {
+	// Prefix is the prefix of the executables' names. Defaults
+	// to the name of the commander followed by a hyphen.
+	Prefix string
+	// Path is a list of directories to search, in the format of
+	// the PATH environment variable. Defaults to the value of
+	// PATH.
+	Path string
+	// EnvPrefix is the prefix for the environment variables used
+	// to pass the values of the commander's flags, other than
+	// secret flags, to external commands, as in
+	// <EnvPrefix>_FLAG_<NAME>. Defaults to the name of the
+	// commander in upper case.
+	EnvPrefix string
+	// Category is the help category for external
+	// commands. Defaults to "external commands".
+	Category string
+}
+
+// ExternalCommands adds external commands discovered from the PATH,
+// with the default options, to the commander.
+func ExternalCommands() Attachment { return ExternalCommandOptions{}.Add }
+
+// Add registers the discovery of external commands with the
+// commander. Discovery happens when the commander is resolved into a
+// cli.Command, so that the commander's name and subcommands are
+// final: external commands never shadow subcommands (or aliases)
+// defined on the commander.
+//
+// External commands receive all remaining arguments, without flag
+// parsing, the environment and the standard input, output, and error
+// streams of the process. The values of the flags set on the root
+// command, except flags marked secret, are passed as environment
+// variables, and exit codes are propagated as ExitError values.
+func (opts ExternalCommandOptions) Add(c *Commander) {
+	c.onResolve(func(c *Commander) {
+		name := secondValueWhenFirstIsZero(c.cmd.Name, c.name.Get())
+		opts.Prefix = secondValueWhenFirstIsZero(opts.Prefix, name+"-")
+		opts.Path = secondValueWhenFirstIsZero(opts.Path, os.Getenv("PATH"))
+		opts.EnvPrefix = secondValueWhenFirstIsZero(opts.EnvPrefix, envVarName(name))
+		opts.Category = secondValueWhenFirstIsZero(opts.Category, "external commands")
+
+		defined := c.subcommandNames()
+		secrets := c.flagNames(isSecretFlag)
+		found := opts.discover()
+		for _, cmdName := range slices.Sorted(maps.Keys(found)) {
+			if defined.Check(cmdName) {
+				continue
+			}
+			path := found[cmdName]
+
+			sub := MakeCommander().
+				SetName(cmdName).
+				SetUsage(fmt.Sprintf("external command (%s)", path)).
+				SetAction(opts.action(path, secrets))
+			sub.cmd.SkipFlagParsing = true
+			sub.cmd.Category = opts.Category
+
+			c.Subcommanders(sub)
+		}
+	})
+}
+
+// discover returns a mapping of command names to paths. As with
+// shells, the first matching executable in the path takes
+// precedence.
+func (opts ExternalCommandOptions) discover() map[string]string {
+	out := map[string]string{}
+	for _, dir := range filepath.SplitList(opts.Path) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			fn := entry.Name()
+			if entry.IsDir() || !strings.HasPrefix(fn, opts.Prefix) || len(fn) == len(opts.Prefix) {
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil || info.Mode()&0o111 == 0 {
+				continue
+			}
+
+			cmdName := strings.TrimSuffix(strings.TrimPrefix(fn, opts.Prefix), ".exe")
+			if _, ok := out[cmdName]; !ok {
+				out[cmdName] = filepath.Join(dir, fn)
+			}
+		}
+	}
+	return out
+}
+
+func (opts ExternalCommandOptions) action(path string, secrets *dt.Set[string]) Action {
+	return func(ctx context.Context, cc *cli.Command) error {
+		cmd := exec.CommandContext(ctx, path, cc.Args().Slice()...)
+		streams := StreamsFromContext(ctx)
+		cmd.Stdin = streams.In
+		cmd.Stdout = streams.Out
+		cmd.Stderr = streams.Err
+		cmd.Env = append(os.Environ(), opts.flagEnv(cc.Root(), secrets)...)
+
+		err := cmd.Run()
+
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			return &ExitError{Command: filepath.Base(path), Code: ee.ExitCode(), Err: err}
+		}
+		return err
+	}
+}
+
+// flagEnv returns the environment variables for the flags set on the
+// root command. Secret flags are never exported, so that their values
+// do not leak into the environment of other processes.
+func (opts ExternalCommandOptions) flagEnv(root *cli.Command, secrets *dt.Set[string]) []string {
+	var out []string
+	for _, flag := range root.Flags {
+		names := flag.Names()
+		if len(names) == 0 || !root.IsSet(names[0]) || secrets.Check(names[0]) {
+			continue
+		}
+
+		var val string
+		switch v := root.Value(names[0]).(type) {
+		case []string:
+			val = strings.Join(v, ",")
+		case []int, []int64:
+			val = strings.Trim(strings.Join(strings.Fields(fmt.Sprint(v)), ","), "[]")
+		default:
+			val = fmt.Sprint(v)
+		}
+
+		out = append(out, fmt.Sprintf("%s_FLAG_%s=%s", opts.EnvPrefix, envVarName(names[0]), val))
+	}
+	return out
+}
+
+// subcommandNames returns the names and aliases of all of the
+// subcommands defined on the commander.
+func (c *Commander) subcommandNames() *dt.Set[string] {
+	out := &dt.Set[string]{}
+	c.subcmds.With(func(in *dt.List[*Commander]) {
+		for sub := range in.IteratorFront() {
+			out.Add(secondValueWhenFirstIsZero(sub.cmd.Name, sub.name.Get()))
+			out.Extend(irt.Slice(sub.cmd.Aliases))
+			sub.aliases.With(func(al *dt.List[string]) { out.Extend(al.IteratorFront()) })
+		}
+	})
+	return out
+}
+
+// envVarName converts a name into a conventional environment
+// variable name (e.g. "dry-run" becomes "DRY_RUN".)
+func envVarName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -0,0 +1,83 @@
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func writeScript(t *testing.T, dir, name, body string) {
+	t.Helper()
+	assert.NotError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+body+"\n"), 0o755))
+}
+
+func TestExternalCommands(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "output")
+	writeScript(t, dir, "tool-hello", `echo "$@" "$TOOL_FLAG_LEVEL" "[$TOOL_FLAG_TOKEN]" > `+out)
+	writeScript(t, dir, "tool-fail", "exit 3")
+	writeScript(t, dir, "tool-builtin", "exit 1")
+	writeScript(t, dir, "tool-cat", "cat; echo oops >&2")
+	assert.NotError(t, os.WriteFile(filepath.Join(dir, "tool-data"), nil, 0o644))
+
+	build := func() *Commander {
+		return MakeRootCommander().SetName("tool").
+			Flags(
+				MakeFlag(&FlagOptions[string]{Name: "level"}),
+				MakeFlag(&FlagOptions[string]{Name: "token", Secret: true}),
+			).
+			Subcommanders(MakeCommander().SetName("builtin").SetAction(func(context.Context, *cli.Command) error { return nil })).
+			With(ExternalCommandOptions{Path: dir}.Add)
+	}
+
+	t.Run("Discovery", func(t *testing.T) {
+		cmd := build()
+		cmd.setContext(ctx)
+		names := map[string]string{}
+		for _, sub := range cmd.Command().Commands {
+			names[sub.Name] = sub.Category
+		}
+		check.Equal(t, len(names), 4)
+		check.Equal(t, names["builtin"], "")
+		check.Equal(t, names["hello"], "external commands")
+		check.Equal(t, names["fail"], "external commands")
+	})
+	t.Run("Execution", func(t *testing.T) {
+		assert.NotError(t, Run(ctx, build(), []string{"tool", "--level", "info", "--token", "hunter2", "hello", "--world", "kip"}))
+		data, err := os.ReadFile(out)
+		assert.NotError(t, err)
+		assert.Equal(t, strings.TrimSpace(string(data)), "--world kip info []")
+	})
+	t.Run("Streams", func(t *testing.T) {
+		stdout, stderr := &strings.Builder{}, &strings.Builder{}
+		cmd := build().SetReader(strings.NewReader("hello\n")).SetWriter(stdout).SetErrWriter(stderr)
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "cat"}))
+		check.Equal(t, stdout.String(), "hello\n")
+		check.Equal(t, stderr.String(), "oops\n")
+	})
+	t.Run("BuiltinPrecedence", func(t *testing.T) {
+		assert.NotError(t, Run(ctx, build(), []string{"tool", "builtin"}))
+	})
+	t.Run("ExitCode", func(t *testing.T) {
+		err := Run(ctx, build(), []string{"tool", "fail"})
+		var ee *ExitError
+		assert.True(t, errors.As(err, &ee))
+		assert.Equal(t, ee.Code, 3)
+		assert.Equal(t, ee.Command, "tool-fail")
+	})
+	t.Run("EnvVarName", func(t *testing.T) {
+		check.Equal(t, envVarName("dry-run"), "DRY_RUN")
+		check.Equal(t, envVarName("my.tool2"), "MY_TOOL2")
+	})
+}
@@ -0,0 +1,245 @@
+package cmdr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/ers"
+	"github.com/tychoish/fun/fnx"
+	"github.com/tychoish/fun/irt"
+	"github.com/tychoish/fun/wpa"
+)
+
+// FanOutOptions makes a command that runs an operation over each of
+// its positional arguments, concurrently, using a worker pool: e.g.
+// "tool fetch a b c d" runs the operation for "a", "b", "c", and "d".
+//
+// The results of the operation are written to the output of the
+// command, either as each item completes or, with the ordered flag,
+// in the order of the arguments. By default, the first failure
+// cancels the context of the remaining items, and the command
+// returns its error; with the keep-going flag, all items run, and
+// the command returns the aggregated errors of all of the items
+// that failed.
+//
+// When the command has a Progress (see ProgressOptions), the command
+// reports its progress as a bar.
+type FanOutOptions[R any] struct {
+	// Operation processes a single argument, and is required.
+	Operation func(context.Context, string) (R, error)
+	// Output writes the result of an item to the output of the
+	// command. Defaults to writing the result on its own line.
+	Output func(w io.Writer, item string, result R) error
+	// OnProgress, when set, is called (serially) after each item
+	// completes.
+	OnProgress func(FanOutProgress)
+
+	// Jobs is the default value of the jobs flag: the number of
+	// items processed concurrently. Defaults to the number of
+	// CPUs.
+	Jobs int
+	// Ordered is the default value of the ordered flag.
+	Ordered bool
+	// KeepGoing is the default value of the keep-going flag.
+	KeepGoing bool
+
+	// JobsFlag, OrderedFlag, and KeepGoingFlag are the names of
+	// the flags. They default to "jobs", "ordered", and
+	// "keep-going".
+	JobsFlag      string
+	OrderedFlag   string
+	KeepGoingFlag string
+}
+
+// FanOutProgress describes the progress of a fan-out command, after
+// an item completes.
+type FanOutProgress struct {
+	// Item is the argument that completed, and Err is its error,
+	// if it failed.
+	Item string
+	Err  error
+	// Completed counts the items that have completed, including
+	// the items that failed, and Failed counts the failures.
+	Completed int
+	Failed    int
+	Total     int
+}
+
+// FanOut makes the commander run the operation over each of its
+// positional arguments, concurrently. See FanOutOptions.
+func FanOut(op Operation[string]) Attachment {
+	return FanOutOptions[struct{}]{
+		Operation: func(ctx context.Context, item string) (struct{}, error) { return struct{}{}, op(ctx, item) },
+		Output:    func(io.Writer, string, struct{}) error { return nil },
+	}.Add
+}
+
+// Add sets the action of the commander, and adds the flags. Use with
+// the Commander.With method.
+func (opts FanOutOptions[R]) Add(c *Commander) {
+	erc.InvariantOk(opts.Operation != nil, "fan-out commands must have an operation")
+	opts.Jobs = secondValueWhenFirstIsZero(opts.Jobs, runtime.NumCPU())
+	opts.JobsFlag = secondValueWhenFirstIsZero(opts.JobsFlag, "jobs")
+	opts.OrderedFlag = secondValueWhenFirstIsZero(opts.OrderedFlag, "ordered")
+	opts.KeepGoingFlag = secondValueWhenFirstIsZero(opts.KeepGoingFlag, "keep-going")
+	if opts.Output == nil {
+		opts.Output = func(w io.Writer, _ string, result R) error { _, err := fmt.Fprintln(w, result); return err }
+	}
+
+	c.Flags(
+		MakeFlag(&FlagOptions[int]{
+			Name:    opts.JobsFlag,
+			Aliases: []string{"j"},
+			Usage:   "number of items to process concurrently",
+			Default: opts.Jobs,
+		}),
+		MakeFlag(&FlagOptions[bool]{
+			Name:    opts.OrderedFlag,
+			Usage:   "write results in the order of the arguments, rather than as they complete",
+			Default: opts.Ordered,
+		}),
+		MakeFlag(&FlagOptions[bool]{
+			Name:    opts.KeepGoingFlag,
+			Usage:   "process all items when an item fails, and report all of the failures",
+			Default: opts.KeepGoing,
+		}),
+	).SetAction(opts.run)
+}
+
+func (opts FanOutOptions[R]) run(ctx context.Context, cc *cli.Command) error {
+	items := cc.Args().Slice()
+	if len(items) == 0 {
+		return fmt.Errorf("arguments for %q: %w", cc.FullName(), ErrNotSpecified)
+	}
+
+	keepGoing := cc.Bool(opts.KeepGoingFlag)
+	rctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fo := &fanOut[R]{
+		opts:    opts,
+		out:     StreamsFromContext(ctx).Out,
+		ordered: cc.Bool(opts.OrderedFlag),
+		items:   items,
+		results: make([]*R, len(items)),
+		done:    make([]bool, len(items)),
+	}
+	if p, ok := ProgressFromContext(ctx); ok {
+		fo.bar = p.Bar(cc.FullName(), int64(len(items)))
+	}
+
+	workers := make([]fnx.Worker, len(items))
+	for idx := range items {
+		workers[idx] = func(context.Context) error {
+			if rctx.Err() != nil {
+				return nil
+			}
+
+			var result R
+			err := runJob(rctx, func(ctx context.Context) (err error) {
+				result, err = opts.Operation(ctx, items[idx])
+				return err
+			})
+			if err != nil && rctx.Err() != nil && ers.IsExpiredContext(err) {
+				// canceled after another item failed, or
+				// by the caller: not a failure of the item.
+				return nil
+			}
+			if !fo.complete(idx, result, err) && !keepGoing {
+				cancel()
+			}
+			return nil
+		}
+	}
+
+	// the workers collect their own errors, and the pool's context
+	// is never canceled, so that the pool waits for all running
+	// items to return: items that have not started when rctx is
+	// canceled return immediately.
+	pool := wpa.RunWithPool(irt.Slice(workers), wpa.WorkerGroupConfNumWorkers(cc.Int(opts.JobsFlag)))
+	err := pool.Run(context.WithoutCancel(ctx))
+	if err == nil {
+		if err = fo.errs.Resolve(); err != nil {
+			err = fmt.Errorf("%d of %d items failed: %w", fo.failed, len(items), err)
+		} else {
+			err = ctx.Err()
+		}
+	}
+	if fo.bar != nil {
+		fo.bar.Done(err)
+	}
+	return err
+}
+
+// fanOut tracks the completed items of a fan-out command, and writes
+// their results.
+type fanOut[R any] struct {
+	opts    FanOutOptions[R]
+	out     io.Writer
+	ordered bool
+	items   []string
+	bar     *ProgressTask
+
+	mtx       sync.Mutex
+	errs      erc.Collector
+	results   []*R
+	done      []bool
+	next      int
+	completed int
+	failed    int
+}
+
+// complete records the result of an item, and reports whether the
+// item succeeded.
+func (fo *fanOut[R]) complete(idx int, result R, err error) bool {
+	fo.mtx.Lock()
+	defer fo.mtx.Unlock()
+
+	item := fo.items[idx]
+	if err == nil {
+		if fo.ordered {
+			fo.results[idx] = &result
+		} else {
+			err = fo.opts.Output(fo.out, item, result)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%q: %w", item, err)
+		fo.errs.Push(err)
+		fo.failed++
+	}
+	fo.completed++
+	fo.done[idx] = true
+
+	if fo.ordered {
+		for ; fo.next < len(fo.done) && fo.done[fo.next]; fo.next++ {
+			if res := fo.results[fo.next]; res != nil {
+				fo.results[fo.next] = nil
+				if oerr := fo.opts.Output(fo.out, fo.items[fo.next], *res); oerr != nil {
+					fo.errs.Push(fmt.Errorf("%q: %w", fo.items[fo.next], oerr))
+					fo.failed++
+				}
+			}
+		}
+	}
+
+	if fo.bar != nil {
+		fo.bar.Add(1)
+	}
+	if fo.opts.OnProgress != nil {
+		fo.opts.OnProgress(FanOutProgress{
+			Item:      item,
+			Err:       err,
+			Completed: fo.completed,
+			Failed:    fo.failed,
+			Total:     len(fo.items),
+		})
+	}
+	return err == nil && fo.errs.Ok()
+}
@@ -0,0 +1,196 @@
+package cmdr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+	"github.com/tychoish/fun/ers"
+)
+
+func TestFanOut(t *testing.T) {
+	ctx := context.Background()
+	errBroken := errors.New("broken")
+
+	run := func(opts FanOutOptions[string], args ...string) (string, error) {
+		out := &bytes.Buffer{}
+		cmd := MakeRootCommander().SetName("tool").With(opts.Add)
+		cmd.cmd.Writer = out
+		err := Run(ctx, cmd, append([]string{"tool"}, args...))
+		return out.String(), err
+	}
+	upper := func(_ context.Context, item string) (string, error) { return strings.ToUpper(item), nil }
+
+	t.Run("Validation", func(t *testing.T) {
+		assert.Panic(t, func() { MakeCommander().With(FanOutOptions[string]{}.Add) })
+
+		_, err := run(FanOutOptions[string]{Operation: upper})
+		assert.ErrorIs(t, err, ErrNotSpecified)
+	})
+	t.Run("Streaming", func(t *testing.T) {
+		out, err := run(FanOutOptions[string]{Operation: upper}, "a", "b", "c")
+		assert.NotError(t, err)
+		lines := strings.Fields(out)
+		check.Equal(t, len(lines), 3)
+		for _, item := range []string{"A", "B", "C"} {
+			check.Substring(t, out, item)
+		}
+	})
+	t.Run("Ordered", func(t *testing.T) {
+		// earlier items finish later, so that streaming output
+		// would be reversed.
+		slow := func(_ context.Context, item string) (string, error) {
+			time.Sleep(time.Duration('e'-item[0]) * 5 * time.Millisecond)
+			return item, nil
+		}
+		out, err := run(FanOutOptions[string]{Operation: slow, Ordered: true}, "--jobs", "4", "a", "b", "c", "d")
+		assert.NotError(t, err)
+		check.Equal(t, out, "a\nb\nc\nd\n")
+
+		// each item waits for the output of the item after it,
+		// so that the items complete in reverse.
+		gates := map[string]chan struct{}{}
+		for _, item := range []string{"a", "b", "c", "d"} {
+			gates[item] = make(chan struct{})
+		}
+		close(gates["d"])
+		prev := map[string]string{"d": "c", "c": "b", "b": "a"}
+		out, err = run(FanOutOptions[string]{
+			Operation: func(ctx context.Context, item string) (string, error) {
+				select {
+				case <-gates[item]:
+					return item, nil
+				case <-ctx.Done():
+					return "", ctx.Err()
+				}
+			},
+			Output: func(w io.Writer, item, result string) error {
+				_, err := fmt.Fprintln(w, result)
+				if next, ok := prev[item]; ok {
+					close(gates[next])
+				}
+				return err
+			},
+		}, "--jobs", "4", "--ordered=false", "a", "b", "c", "d")
+		assert.NotError(t, err)
+		check.Equal(t, out, "d\nc\nb\na\n")
+	})
+	t.Run("Output", func(t *testing.T) {
+		out, err := run(FanOutOptions[string]{
+			Operation: upper,
+			Output: func(w io.Writer, item, result string) error {
+				_, err := fmt.Fprintf(w, "%s=%s\n", item, result)
+				return err
+			},
+		}, "--ordered", "a", "b")
+		assert.NotError(t, err)
+		check.Equal(t, out, "a=A\nb=B\n")
+	})
+	t.Run("Jobs", func(t *testing.T) {
+		var running, peak atomic.Int64
+		op := func(_ context.Context, item string) (string, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(5 * time.Millisecond)
+			return item, nil
+		}
+
+		_, err := run(FanOutOptions[string]{Operation: op}, "--jobs", "2", "a", "b", "c", "d", "e", "f")
+		assert.NotError(t, err)
+		check.True(t, peak.Load() <= 2)
+
+		peak.Store(0)
+		_, err = run(FanOutOptions[string]{Operation: op}, "-j", "1", "a", "b", "c")
+		assert.NotError(t, err)
+		check.Equal(t, peak.Load(), 1)
+	})
+	t.Run("FailFast", func(t *testing.T) {
+		var started, canceled atomic.Int64
+		op := func(ctx context.Context, item string) (string, error) {
+			if item == "bad" {
+				waitFor(t, func() bool { return started.Load() == 2 })
+				return "", errBroken
+			}
+			started.Add(1)
+			<-ctx.Done()
+			canceled.Add(1)
+			return "", ctx.Err()
+		}
+
+		out, err := run(FanOutOptions[string]{Operation: op, Jobs: 3}, "a", "bad", "b")
+		assert.ErrorIs(t, err, errBroken)
+		check.NotErrorIs(t, err, context.Canceled)
+		check.Equal(t, err.Error(), `1 of 3 items failed: "bad": broken`)
+		check.Equal(t, canceled.Load(), 2)
+		check.Equal(t, out, "")
+	})
+	t.Run("KeepGoing", func(t *testing.T) {
+		var progress []FanOutProgress
+		var mtx sync.Mutex
+		op := func(_ context.Context, item string) (string, error) {
+			switch item {
+			case "bad":
+				return "", errBroken
+			case "panic":
+				panic("oops")
+			}
+			return item, nil
+		}
+
+		out, err := run(FanOutOptions[string]{
+			Operation: op,
+			OnProgress: func(p FanOutProgress) {
+				mtx.Lock()
+				defer mtx.Unlock()
+				progress = append(progress, p)
+			},
+		}, "--keep-going", "--ordered", "a", "bad", "b", "panic", "c")
+		assert.ErrorIs(t, err, errBroken)
+		assert.ErrorIs(t, err, ers.ErrRecoveredPanic)
+		check.Substring(t, err.Error(), "2 of 5 items failed")
+		check.Equal(t, out, "a\nb\nc\n")
+
+		assert.Equal(t, len(progress), 5)
+		last := progress[len(progress)-1]
+		check.Equal(t, last.Completed, 5)
+		check.Equal(t, last.Failed, 2)
+		check.Equal(t, last.Total, 5)
+		for _, p := range progress {
+			check.Equal(t, p.Err != nil, p.Item == "bad" || p.Item == "panic")
+		}
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cmd := MakeRootCommander().SetName("tool").With(FanOut(func(ctx context.Context, _ string) error {
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+
+		assert.ErrorIs(t, Run(ctx, cmd, []string{"tool", "a", "b"}), context.Canceled)
+	})
+	t.Run("Operation", func(t *testing.T) {
+		var seen sync.Map
+		cmd := MakeRootCommander().SetName("tool").With(FanOut(func(_ context.Context, item string) error {
+			seen.Store(item, true)
+			return nil
+		}))
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "a", "b", "c"}))
+		for _, item := range []string{"a", "b", "c"} {
+			_, ok := seen.Load(item)
+			check.True(t, ok)
+		}
+	})
+}
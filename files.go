@@ -0,0 +1,336 @@
+package cmdr
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/srv"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// InputOptions defines a flag that names the input of a command: the
+// path of a file, or "-", or nothing, for standard input. Open
+// resolves the flag to a reader.
+//
+// Readers are closed by the srv package's cleanup when the command
+// returns, if the action does not close them first.
+type InputOptions struct {
+	// Name is the name of the flag. Defaults to "input".
+	Name    string
+	Aliases []string
+	Usage   string
+	// Required makes the flag required. Pass "-" to read
+	// standard input.
+	Required bool
+	// FirstArg reads the path from the first positional argument,
+	// when the flag is not set.
+	FirstArg bool
+	// Decompress detects gzip and zstd compressed input, by its
+	// leading bytes, and decompresses it.
+	Decompress bool
+}
+
+// WithInput adds an input flag, using the default options, to the
+// commander. Use InputOptions{}.Open to open the input.
+func WithInput() Attachment { return InputOptions{}.Add }
+
+// Add adds the input flag to the commander. Use with the
+// Commander.With method.
+func (opts InputOptions) Add(c *Commander) { c.Flags(opts.Flag()) }
+
+// Flag returns the input flag.
+func (opts InputOptions) Flag() Flag {
+	return MakeFlag(&FlagOptions[string]{
+		Name:      opts.name(),
+		Aliases:   opts.Aliases,
+		Usage:     secondValueWhenFirstIsZero(opts.Usage, "read input from `PATH`, or - for standard input"),
+		Required:  opts.Required,
+		TakesFile: true,
+	})
+}
+
+func (opts InputOptions) name() string { return secondValueWhenFirstIsZero(opts.Name, "input") }
+
+// Open opens the input named by the flag. Standard input is the
+// input of the command (see Streams); closing the reader does not
+// close standard input.
+func (opts InputOptions) Open(ctx context.Context, cc *cli.Command) (io.ReadCloser, error) {
+	path := cc.String(opts.name())
+	if opts.FirstArg {
+		path = GetFlagOrFirstArg[string](cc, opts.name())
+	}
+
+	in := &inputStream{}
+	if path == "" || path == "-" {
+		path = "<stdin>"
+		in.Reader = StreamsFromContext(ctx).In
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("opening input: %w", err)
+		}
+		in.Reader, in.file = file, file
+	}
+
+	if opts.Decompress {
+		if err := in.decompress(); err != nil {
+			return nil, erc.Join(fmt.Errorf("reading input %q: %w", path, err), in.Close())
+		}
+	}
+
+	if srv.HasCleanup(ctx) {
+		srv.AddCleanup(ctx, in.cleanup)
+	}
+	return in, nil
+}
+
+type inputStream struct {
+	io.Reader
+	file   *os.File
+	closer func() error
+	once   sync.Once
+	err    error
+}
+
+func (s *inputStream) decompress() error {
+	buf := bufio.NewReader(s.Reader)
+	s.Reader = buf
+
+	magic, err := buf.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(buf)
+		if err != nil {
+			return fmt.Errorf("gzip: %w", err)
+		}
+		s.Reader, s.closer = zr, zr.Close
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(buf)
+		if err != nil {
+			return fmt.Errorf("zstd: %w", err)
+		}
+		s.Reader, s.closer = zr, func() error { zr.Close(); return nil }
+	}
+	return nil
+}
+
+func (s *inputStream) Close() error {
+	s.once.Do(func() { s.err = s.close() })
+	return s.err
+}
+
+// cleanup closes the stream when the action did not, and only
+// reports errors from closing it then.
+func (s *inputStream) cleanup(context.Context) (err error) {
+	s.once.Do(func() { s.err = s.close(); err = s.err })
+	return err
+}
+
+func (s *inputStream) close() error {
+	var ec erc.Collector
+	if s.closer != nil {
+		ec.Push(s.closer())
+	}
+	if s.file != nil {
+		ec.Push(s.file.Close())
+	}
+	return ec.Resolve()
+}
+
+// OutputOptions defines a flag that names the output of a command:
+// the path of a file, or "-", or nothing, for standard output. Open
+// resolves the flag to a writer.
+//
+// Close the writer to complete the output. Writers that the action
+// does not close are closed by the srv package's cleanup when the
+// command returns; atomic outputs are discarded then, so that
+// commands that fail never replace the file.
+type OutputOptions struct {
+	// Name is the name of the flag. Defaults to "output".
+	Name    string
+	Aliases []string
+	Usage   string
+	// Required makes the flag required. Pass "-" to write to
+	// standard output.
+	Required bool
+	// Compress compresses output written to files with the ".gz"
+	// (gzip) or ".zst" (zstd) extensions.
+	Compress bool
+	// Atomic writes the output to a temporary file, in the same
+	// directory, and renames the temporary file to the path when
+	// the writer is closed, so that readers never see partial
+	// output.
+	Atomic bool
+	// Perm is the mode of the files that Open creates. Defaults
+	// to 0644. The umask applies to files that are not atomic.
+	Perm os.FileMode
+}
+
+// WithOutput adds an output flag, using the default options, to the
+// commander. Use OutputOptions{}.Open to open the output.
+func WithOutput() Attachment { return OutputOptions{}.Add }
+
+// Add adds the output flag to the commander. Use with the
+// Commander.With method.
+func (opts OutputOptions) Add(c *Commander) { c.Flags(opts.Flag()) }
+
+// Flag returns the output flag.
+func (opts OutputOptions) Flag() Flag {
+	return MakeFlag(&FlagOptions[string]{
+		Name:      opts.name(),
+		Aliases:   opts.Aliases,
+		Usage:     secondValueWhenFirstIsZero(opts.Usage, "write output to `PATH`, or - for standard output"),
+		Required:  opts.Required,
+		TakesFile: true,
+	})
+}
+
+func (opts OutputOptions) name() string { return secondValueWhenFirstIsZero(opts.Name, "output") }
+
+// Open opens the output named by the flag. Standard output is the
+// output of the command (see Streams); closing the writer does not
+// close standard output, and output to standard output is never
+// compressed.
+func (opts OutputOptions) Open(ctx context.Context, cc *cli.Command) (io.WriteCloser, error) {
+	path := cc.String(opts.name())
+
+	out := &outputStream{}
+	if path == "" || path == "-" {
+		out.Writer = StreamsFromContext(ctx).Out
+	} else {
+		perm := secondValueWhenFirstIsZero(opts.Perm, 0o644)
+
+		var err error
+		if opts.Atomic {
+			out.path = path
+			out.file, err = os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+			if err == nil {
+				if err = out.file.Chmod(perm); err != nil {
+					err = erc.Join(err, out.abort())
+				}
+			}
+		} else {
+			out.file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("opening output: %w", err)
+		}
+		out.Writer = out.file
+
+		if opts.Compress {
+			if err := out.compress(path); err != nil {
+				return nil, erc.Join(fmt.Errorf("writing output %q: %w", path, err), out.abort())
+			}
+		}
+	}
+
+	if srv.HasCleanup(ctx) {
+		srv.AddCleanup(ctx, out.cleanup)
+	}
+	return out, nil
+}
+
+type outputStream struct {
+	io.Writer
+	file *os.File
+	// path is the destination of atomic outputs, and is
+	// otherwise empty.
+	path       string
+	compressor io.WriteCloser
+	once       sync.Once
+	err        error
+}
+
+func (s *outputStream) compress(path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz":
+		s.compressor = gzip.NewWriter(s.Writer)
+	case ".zst":
+		zw, err := zstd.NewWriter(s.Writer)
+		if err != nil {
+			return fmt.Errorf("zstd: %w", err)
+		}
+		s.compressor = zw
+	default:
+		return nil
+	}
+	s.Writer = s.compressor
+	return nil
+}
+
+func (s *outputStream) Close() error {
+	s.once.Do(func() { s.err = s.commit() })
+	return s.err
+}
+
+// cleanup closes (or, for atomic outputs, discards) the stream when
+// the action did not close it, and only reports errors from closing
+// it then.
+func (s *outputStream) cleanup(context.Context) (err error) {
+	s.once.Do(func() {
+		if s.path != "" {
+			err = s.abort()
+		} else {
+			err = s.commit()
+		}
+		s.err = err
+	})
+	return err
+}
+
+func (s *outputStream) commit() error {
+	if s.compressor != nil {
+		if err := s.compressor.Close(); err != nil {
+			return erc.Join(fmt.Errorf("flushing output: %w", err), s.abort())
+		}
+	}
+
+	switch {
+	case s.file == nil:
+		return nil
+	case s.path == "":
+		return s.file.Close()
+	}
+
+	if err := s.file.Sync(); err != nil {
+		return erc.Join(fmt.Errorf("writing output %q: %w", s.path, err), s.abort())
+	}
+	if err := s.file.Close(); err != nil {
+		return erc.Join(fmt.Errorf("writing output %q: %w", s.path, err), os.Remove(s.file.Name()))
+	}
+	if err := os.Rename(s.file.Name(), s.path); err != nil {
+		return erc.Join(fmt.Errorf("replacing output: %w", err), os.Remove(s.file.Name()))
+	}
+	return nil
+}
+
+// abort closes the file, and removes it when the output is atomic.
+func (s *outputStream) abort() error {
+	if s.file == nil {
+		return nil
+	}
+	if s.path == "" {
+		return s.file.Close()
+	}
+	return erc.Join(s.file.Close(), os.Remove(s.file.Name()))
+}
@@ -0,0 +1,182 @@
+package cmdr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func TestFiles(t *testing.T) {
+	ctx := context.Background()
+
+	// copyCommand copies the input to the output, and then returns
+	// fail, after closing the output when close is set.
+	copyCommand := func(in InputOptions, out OutputOptions, close bool, fail error) *Commander {
+		return MakeRootCommander().SetName("copy").
+			With(in.Add).
+			With(out.Add).
+			SetAction(func(ctx context.Context, cc *cli.Command) error {
+				r, err := in.Open(ctx, cc)
+				if err != nil {
+					return err
+				}
+				w, err := out.Open(ctx, cc)
+				if err != nil {
+					return err
+				}
+				if _, err := io.Copy(w, r); err != nil {
+					return err
+				}
+				if close {
+					if err := w.Close(); err != nil {
+						return err
+					}
+				}
+				return fail
+			})
+	}
+	write := func(t *testing.T, path, content string) {
+		t.Helper()
+		assert.NotError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	read := func(t *testing.T, path string) string {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		assert.NotError(t, err)
+		return string(data)
+	}
+
+	t.Run("Standard", func(t *testing.T) {
+		for _, args := range [][]string{{}, {"--input", "-", "--output", "-"}} {
+			out := &bytes.Buffer{}
+			cmd := copyCommand(InputOptions{}, OutputOptions{}, true, nil)
+			cmd.cmd.Reader = strings.NewReader("hello\n")
+			cmd.cmd.Writer = out
+			assert.NotError(t, Run(ctx, cmd, append([]string{"copy"}, args...)))
+			check.Equal(t, out.String(), "hello\n")
+		}
+	})
+	t.Run("Files", func(t *testing.T) {
+		dir := t.TempDir()
+		src, dst := filepath.Join(dir, "in.txt"), filepath.Join(dir, "out.txt")
+		write(t, src, "hello\n")
+		write(t, dst, "some longer previous content\n")
+
+		// the output is closed by the cleanup
+		cmd := copyCommand(InputOptions{}, OutputOptions{Perm: 0o600}, false, nil)
+		assert.NotError(t, Run(ctx, cmd, []string{"copy", "--input", src, "--output", dst}))
+		check.Equal(t, read(t, dst), "hello\n")
+
+		assert.NotError(t, os.Remove(dst))
+		assert.NotError(t, Run(ctx, cmd, []string{"copy", "--input", src, "--output", dst}))
+		info, err := os.Stat(dst)
+		assert.NotError(t, err)
+		check.Equal(t, info.Mode().Perm()&0o077, 0)
+	})
+	t.Run("FirstArg", func(t *testing.T) {
+		dir := t.TempDir()
+		src, dst := filepath.Join(dir, "in.txt"), filepath.Join(dir, "out.txt")
+		write(t, src, "hello\n")
+
+		cmd := copyCommand(InputOptions{FirstArg: true}, OutputOptions{}, true, nil)
+		assert.NotError(t, Run(ctx, cmd, []string{"copy", "--output", dst, src}))
+		check.Equal(t, read(t, dst), "hello\n")
+	})
+	t.Run("Missing", func(t *testing.T) {
+		dir := t.TempDir()
+		cmd := copyCommand(InputOptions{}, OutputOptions{}, true, nil)
+		err := Run(ctx, cmd, []string{"copy", "--input", filepath.Join(dir, "none")})
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		check.Substring(t, err.Error(), "opening input")
+
+		err = Run(ctx, cmd, []string{"copy", "--output", filepath.Join(dir, "none", "out")})
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		check.Substring(t, err.Error(), "opening output")
+	})
+	t.Run("Atomic", func(t *testing.T) {
+		dir := t.TempDir()
+		src, dst := filepath.Join(dir, "in.txt"), filepath.Join(dir, "out.txt")
+		write(t, src, "new\n")
+		write(t, dst, "old\n")
+		args := []string{"copy", "--input", src, "--output", dst}
+		opts := OutputOptions{Atomic: true}
+
+		// failed commands and outputs that are not closed do not
+		// replace the file.
+		failure := errors.New("failure")
+		assert.ErrorIs(t, Run(ctx, copyCommand(InputOptions{}, opts, false, failure), args), failure)
+		check.Equal(t, read(t, dst), "old\n")
+		assert.NotError(t, Run(ctx, copyCommand(InputOptions{}, opts, false, nil), args))
+		check.Equal(t, read(t, dst), "old\n")
+
+		assert.NotError(t, Run(ctx, copyCommand(InputOptions{}, opts, true, nil), args))
+		check.Equal(t, read(t, dst), "new\n")
+		info, err := os.Stat(dst)
+		assert.NotError(t, err)
+		check.Equal(t, info.Mode().Perm(), 0o644)
+
+		entries, err := os.ReadDir(dir)
+		assert.NotError(t, err)
+		check.Equal(t, len(entries), 2)
+	})
+	t.Run("Compression", func(t *testing.T) {
+		for _, ext := range []string{".gz", ".zst"} {
+			t.Run(ext, func(t *testing.T) {
+				dir := t.TempDir()
+				src := filepath.Join(dir, "in.txt")
+				mid := filepath.Join(dir, "mid.txt"+ext)
+				dst := filepath.Join(dir, "out.txt")
+				write(t, src, "hello\n")
+
+				cmd := copyCommand(InputOptions{Decompress: true}, OutputOptions{Compress: true, Atomic: true}, true, nil)
+				assert.NotError(t, Run(ctx, cmd, []string{"copy", "--input", src, "--output", mid}))
+				compressed := read(t, mid)
+				check.NotEqual(t, compressed, "hello\n")
+
+				var dec io.Reader
+				var err error
+				if ext == ".gz" {
+					dec, err = gzip.NewReader(strings.NewReader(compressed))
+				} else {
+					dec, err = zstd.NewReader(strings.NewReader(compressed))
+				}
+				assert.NotError(t, err)
+				data, err := io.ReadAll(dec)
+				assert.NotError(t, err)
+				check.Equal(t, string(data), "hello\n")
+
+				assert.NotError(t, Run(ctx, cmd, []string{"copy", "--input", mid, "--output", dst}))
+				check.Equal(t, read(t, dst), "hello\n")
+			})
+		}
+		t.Run("Plain", func(t *testing.T) {
+			for _, input := range []string{"", "h", "hello\n"} {
+				out := &bytes.Buffer{}
+				cmd := copyCommand(InputOptions{Decompress: true}, OutputOptions{Compress: true}, true, nil)
+				cmd.cmd.Reader = strings.NewReader(input)
+				cmd.cmd.Writer = out
+				assert.NotError(t, Run(ctx, cmd, []string{"copy"}))
+				check.Equal(t, out.String(), input)
+			}
+		})
+		t.Run("Corrupt", func(t *testing.T) {
+			cmd := copyCommand(InputOptions{Decompress: true}, OutputOptions{}, true, nil)
+			cmd.cmd.Reader = strings.NewReader("\x1f\x8bnot gzip")
+			err := Run(ctx, cmd, []string{"copy"})
+			check.Error(t, err)
+			check.Substring(t, err.Error(), "<stdin>")
+		})
+	})
+}
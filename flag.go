@@ -32,6 +32,25 @@ type FlagOptions[T FlagTypes] struct {
 	Validate  func(T) error
 	EnvVars   []string
 
+	// Secret marks flags whose values must not be reported,
+	// (e.g. in crash reports,) because they contain passwords,
+	// tokens or other credentials.
+	Secret bool
+
+	// Prompt is the question that asks the user for the value of
+	// the flag, when the flag is not set and the command is
+	// interactive (see PromptOptions.) Boolean flags ask to
+	// confirm, flags with Choices ask the user to select one of
+	// the choices, Secret flags ask for a password, without
+	// echo, and all other flags ask for text.
+	Prompt string
+	// Choices are the values offered by the prompt.
+	Choices []string
+
+	// Category groups the flag with the other flags of the same
+	// category in the help.
+	Category string
+
 	TimestampLayout string
 
 	// Default values are provided to the parser for many
@@ -86,6 +105,10 @@ func (fo *FlagOptions[T]) SetFilePath(s string) *FlagOptions[T]        { fo.File
 func (fo *FlagOptions[T]) SetRequired(b bool) *FlagOptions[T]          { fo.Required = b; return fo }
 func (fo *FlagOptions[T]) SetHidden(b bool) *FlagOptions[T]            { fo.Hidden = b; return fo }
 func (fo *FlagOptions[T]) SetTakesFile(b bool) *FlagOptions[T]         { fo.TakesFile = b; return fo }
+func (fo *FlagOptions[T]) SetSecret(b bool) *FlagOptions[T]            { fo.Secret = b; return fo }
+func (fo *FlagOptions[T]) SetPrompt(q string) *FlagOptions[T]          { fo.Prompt = q; return fo }
+func (fo *FlagOptions[T]) SetChoices(c ...string) *FlagOptions[T]      { fo.Choices = c; return fo }
+func (fo *FlagOptions[T]) SetCategory(c string) *FlagOptions[T]        { fo.Category = c; return fo }
 func (fo *FlagOptions[T]) SetValidate(v func(T) error) *FlagOptions[T] { fo.Validate = v; return fo }
 func (fo *FlagOptions[T]) SetDefault(d T) *FlagOptions[T]              { fo.Default = d; return fo }
 func (fo *FlagOptions[T]) SetDestination(p *T) *FlagOptions[T]         { fo.Destination = p; return fo }
@@ -104,6 +127,8 @@ func (fo *FlagOptions[T]) doValidate(in T) error {
 type Flag struct {
 	value        cli.Flag
 	validateOnce *adt.Once[error]
+	secret       bool
+	prompt       func(*Prompter, *cli.Command) error
 }
 
 // buildSources creates a ValueSource chain from FilePath and EnvVars
@@ -122,7 +147,10 @@ func buildSources(filePath string, envVars []string) cli.ValueSourceChain {
 // typed flag to options to a flag object for the command
 // line.
 func MakeFlag[T FlagTypes](opts *FlagOptions[T]) Flag {
-	out := Flag{validateOnce: &adt.Once[error]{}}
+	out := Flag{validateOnce: &adt.Once[error]{}, secret: opts.Secret}
+	if opts.Prompt != "" {
+		out.prompt = func(p *Prompter, cc *cli.Command) error { return promptFlag(p, cc, opts) }
+	}
 
 	switch dval := any(opts.Default).(type) {
 	case string:
@@ -327,6 +355,10 @@ func MakeFlag[T FlagTypes](opts *FlagOptions[T]) Flag {
 		erc.InvariantOk(opts.Destination == nil, "cannot specify destination for slice values")
 	}
 
+	if cf, ok := out.value.(cli.CategorizableFlag); ok && opts.Category != "" {
+		cf.SetCategory(opts.Category)
+	}
+
 	return out
 }
 
@@ -0,0 +1,216 @@
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/adt"
+	"github.com/tychoish/fun/dt"
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/ers"
+	"github.com/tychoish/fun/srv"
+)
+
+// ErrNotReady is at the root of the errors returned by Health.Ready.
+const ErrNotReady = ers.Error("not ready")
+
+var healthCtxKey = MakeContextKey[*Health]("cmdr.health")
+
+// HealthCheck reports an error when a dependency of a command is not
+// ready.
+type HealthCheck func(context.Context) error
+
+// Health tracks the readiness of a command: a command is ready when
+// its orchestrator is running, the services it watches are running,
+// all registered checks pass, and it is not shutting down. The zero
+// value is ready to use, and all methods are safe for concurrent
+// use.
+//
+// Use the HealthOptions attachment to expose the health of a command
+// over HTTP, and HealthFromContext to register checks and services
+// from hooks, middleware, and operations.
+type Health struct {
+	checks   adt.SyncMap[string, HealthCheck]
+	services adt.SyncMap[string, *srv.Service]
+	draining atomic.Bool
+}
+
+// HealthFromContext returns the health tracker attached to the
+// context by the HealthOptions attachment.
+func HealthFromContext(ctx context.Context) (*Health, bool) { return healthCtxKey.Get(ctx) }
+
+// AddCheck registers a readiness check, replacing any check
+// previously registered with the same name.
+func (h *Health) AddCheck(name string, check HealthCheck) {
+	erc.InvariantOk(check != nil, "health check", name, "must not be nil")
+	h.checks.Store(name, check)
+}
+
+// Watch requires that the service is running for the command to be
+// ready.
+func (h *Health) Watch(s *srv.Service) { h.services.Store(s.Name, s) }
+
+// Drain marks the command as shutting down, after which it is never
+// ready. The HealthOptions attachment drains the command when the
+// graceful shutdown begins.
+func (h *Health) Drain()           { h.draining.Store(true) }
+func (h *Health) IsDraining() bool { return h.draining.Load() }
+
+// Ready runs all checks and returns an error, rooted in ErrNotReady,
+// that describes every reason that the command is not ready.
+func (h *Health) Ready(ctx context.Context) error {
+	if h.IsDraining() {
+		return fmt.Errorf("shutting down: %w", ErrNotReady)
+	}
+
+	var ec erc.Collector
+	if srv.HasOrchestrator(ctx) && !srv.GetOrchestrator(ctx).Service().Running() {
+		ec.Push(errors.New("orchestrator is not running"))
+	}
+
+	for _, name := range slices.Sorted(h.services.Keys()) {
+		if s, ok := h.services.Load(name); ok && !s.Running() {
+			ec.Push(fmt.Errorf("service %q is not running", name))
+		}
+	}
+
+	for _, name := range slices.Sorted(h.checks.Keys()) {
+		if check, ok := h.checks.Load(name); ok {
+			ec.Wrapf(check(ctx), "check %q", name)
+		}
+	}
+
+	if err := ec.Resolve(); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotReady, err)
+	}
+	return nil
+}
+
+func (h *Health) handler(timeout time.Duration) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(rw, "ok")
+	})
+	mux.HandleFunc("/readyz", func(rw http.ResponseWriter, r *http.Request) {
+		rctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := h.Ready(rctx); err != nil {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(rw, err)
+			return
+		}
+		fmt.Fprintln(rw, "ok")
+	})
+	return mux
+}
+
+// HealthOptions configures the health endpoints for a command. When
+// the health flag is set, the command starts an HTTP server, as a
+// service managed by the srv.Orchestrator, that serves:
+//
+//	/healthz  200 while the process is serving requests.
+//	/readyz   200 when the command is ready, and 503, with the
+//	          reasons, otherwise.
+//
+// When the graceful shutdown of the command begins (i.e. the srv
+// shutdown signal fires,) the command reports that it is not ready,
+// and the orchestrator waits for the ShutdownDelay before it stops
+// any service, so that load balancers stop routing traffic to the
+// command before its services stop. Canceling the context passed to
+// Run stops the services without the delay. Attach health to the
+// root commander.
+type HealthOptions struct {
+	// Health is the health tracker. When nil, the commander
+	// creates a new tracker.
+	Health *Health
+	// FlagName is the name of the flag that specifies the
+	// address of the HTTP server. Defaults to "health-addr".
+	FlagName string
+	// CheckTimeout bounds the duration of the readiness checks
+	// for each request. Defaults to 5 seconds.
+	CheckTimeout time.Duration
+	// ShutdownDelay is how long the command reports that it is
+	// not ready, during shutdown, before the services (and the
+	// HTTP server) stop.
+	ShutdownDelay time.Duration
+}
+
+// WithHealth enables the health endpoints, using the default
+// options, for the commander.
+func WithHealth() Attachment { return HealthOptions{}.Add }
+
+// Add attaches the health endpoints to the commander. Use with the
+// Commander.With method.
+func (opts HealthOptions) Add(c *Commander) {
+	opts.FlagName = secondValueWhenFirstIsZero(opts.FlagName, "health-addr")
+	opts.CheckTimeout = secondValueWhenFirstIsZero(opts.CheckTimeout, 5*time.Second)
+	if opts.Health == nil {
+		opts.Health = &Health{}
+	}
+
+	c.Flags(MakeFlag(&FlagOptions[string]{
+		Name:  opts.FlagName,
+		Usage: "serve health and readiness endpoints over HTTP on this address (e.g. localhost:8080)",
+	}))
+
+	c.hook.With(func(in *dt.List[Action]) {
+		in.PushFront(func(context.Context, *cli.Command) error {
+			c.setContext(healthCtxKey.Set(c.getContext(), opts.Health))
+			return nil
+		})
+	})
+
+	c.AddMiddleware(MiddlewareOptions{
+		Name:     "health",
+		Priority: math.MinInt,
+		Middleware: func(ctx context.Context, cc *cli.Command) (context.Context, error) {
+			addr := cc.String(opts.FlagName)
+			if addr == "" {
+				return ctx, nil
+			}
+
+			opts.Health.draining.Store(false)
+			if err := startHTTPService(ctx, "health", addr, opts.Health.handler(opts.CheckTimeout), opts.Health.Drain); err != nil {
+				return ctx, err
+			}
+			return opts.delayShutdown(ctx), nil
+		},
+	})
+}
+
+// delayShutdown replaces the shutdown signal of the context, so that
+// when the signal fires the command drains, and only triggers the
+// shutdown of the orchestrator after the delay.
+func (opts HealthOptions) delayShutdown(ctx context.Context) context.Context {
+	if !srv.HasShutdownSignal(ctx) {
+		return ctx
+	}
+
+	parent, shutdown := ctx, srv.GetShutdownSignal(ctx)
+	ctx = srv.SetShutdownSignal(ctx)
+	go func() {
+		<-ctx.Done()
+		if parent.Err() == nil {
+			opts.Health.Drain()
+			timer := time.NewTimer(opts.ShutdownDelay)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-parent.Done():
+			}
+		}
+		shutdown()
+	}()
+	return ctx
+}
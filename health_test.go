@@ -0,0 +1,142 @@
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+	"github.com/tychoish/fun/srv"
+)
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NotError(t, err)
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func httpGet(url string) (int, string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body), err
+}
+
+func TestHealth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("Ready", func(t *testing.T) {
+		t.Run("Empty", func(t *testing.T) {
+			h := &Health{}
+			assert.NotError(t, h.Ready(ctx))
+		})
+		t.Run("Checks", func(t *testing.T) {
+			h := &Health{}
+			h.AddCheck("db", func(context.Context) error { return errors.New("unreachable") })
+			h.AddCheck("cache", func(context.Context) error { return nil })
+			err := h.Ready(ctx)
+			assert.ErrorIs(t, err, ErrNotReady)
+			check.Substring(t, err.Error(), `check "db"`)
+			check.Substring(t, err.Error(), "unreachable")
+			check.NotSubstring(t, err.Error(), "cache")
+
+			h.AddCheck("db", func(context.Context) error { return nil })
+			assert.NotError(t, h.Ready(ctx))
+		})
+		t.Run("NilCheck", func(t *testing.T) {
+			assert.Panic(t, func() { (&Health{}).AddCheck("nil", nil) })
+		})
+		t.Run("Services", func(t *testing.T) {
+			h := &Health{}
+			s := &srv.Service{Name: "worker", Run: func(ctx context.Context) error { <-ctx.Done(); return nil }}
+			h.Watch(s)
+			err := h.Ready(ctx)
+			assert.ErrorIs(t, err, ErrNotReady)
+			check.Substring(t, err.Error(), `service "worker" is not running`)
+
+			sctx, scancel := context.WithCancel(ctx)
+			assert.NotError(t, s.Start(sctx))
+			assert.NotError(t, h.Ready(ctx))
+			scancel()
+			assert.NotError(t, s.Wait())
+			assert.Error(t, h.Ready(ctx))
+		})
+		t.Run("Draining", func(t *testing.T) {
+			h := &Health{}
+			h.Drain()
+			check.True(t, h.IsDraining())
+			err := h.Ready(ctx)
+			assert.ErrorIs(t, err, ErrNotReady)
+			check.Substring(t, err.Error(), "shutting down")
+		})
+	})
+	t.Run("Context", func(t *testing.T) {
+		h := &Health{}
+		cmd := MakeRootCommander().SetName("tool").
+			With(HealthOptions{Health: h}.Add).
+			SetAction(func(ctx context.Context, _ *cli.Command) error {
+				got, ok := HealthFromContext(ctx)
+				check.True(t, ok)
+				check.True(t, got == h)
+				return nil
+			})
+		assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+	})
+	t.Run("Endpoints", func(t *testing.T) {
+		addr := freeAddr(t)
+		worker := &srv.Service{Name: "worker", Run: func(ctx context.Context) error { <-ctx.Done(); return nil }}
+		cmd := MakeRootCommander().SetName("tool").
+			With(HealthOptions{ShutdownDelay: 250 * time.Millisecond}.Add).
+			SetAction(func(ctx context.Context, _ *cli.Command) error {
+				assert.NotError(t, srv.GetOrchestrator(ctx).Add(worker))
+
+				code, body, err := httpGet("http://" + addr + "/healthz")
+				assert.NotError(t, err)
+				check.Equal(t, code, http.StatusOK)
+				check.Equal(t, body, "ok\n")
+
+				code, _, err = httpGet("http://" + addr + "/readyz")
+				assert.NotError(t, err)
+				check.Equal(t, code, http.StatusOK)
+
+				h, _ := HealthFromContext(ctx)
+				h.AddCheck("db", func(context.Context) error { return errors.New("unreachable") })
+				code, body, err = httpGet("http://" + addr + "/readyz")
+				assert.NotError(t, err)
+				check.Equal(t, code, http.StatusServiceUnavailable)
+				check.Substring(t, body, "unreachable")
+				h.AddCheck("db", func(context.Context) error { return nil })
+
+				srv.GetShutdownSignal(ctx)()
+				for !h.IsDraining() {
+					time.Sleep(time.Millisecond)
+				}
+
+				code, body, err = httpGet("http://" + addr + "/readyz")
+				assert.NotError(t, err)
+				check.Equal(t, code, http.StatusServiceUnavailable)
+				check.Substring(t, body, "shutting down")
+				// the other services run until the delay passes.
+				check.True(t, worker.Running())
+				return nil
+			})
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "--health-addr", addr}))
+		_, _, err := httpGet("http://" + addr + "/healthz")
+		assert.Error(t, err)
+		check.True(t, !worker.Running())
+	})
+}
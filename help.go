@@ -0,0 +1,514 @@
+package cmdr
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/dt"
+	"github.com/tychoish/fun/irt"
+)
+
+// keys of the cli.Command metadata that the help renderer reads.
+const (
+	helpOptionsKey  = "cmdr.help"
+	helpExamplesKey = "cmdr.examples"
+)
+
+const (
+	helpIndent    = 2
+	helpGap       = 3
+	helpMaxColumn = 32
+	helpMinWidth  = 20
+)
+
+// Example is an example use of a command, which the help shows (see
+// Commander.Examples.)
+type Example struct {
+	// Description explains the example.
+	Description string
+	// Command is the command line, without the shell prompt.
+	Command string
+}
+
+// HelpTheme styles the parts of the help with the parameters of ANSI
+// "select graphic rendition" sequences: for instance "1" for bold, or
+// "1;36" for bold cyan. Parts with empty parameters are not styled.
+type HelpTheme struct {
+	Heading     string
+	Command     string
+	Flag        string
+	Placeholder string
+	// Muted styles defaults, environment variables, and other
+	// details of the flags.
+	Muted   string
+	Example string
+}
+
+// DefaultHelpTheme returns the theme that HelpOptions uses when the
+// options do not specify a theme.
+func DefaultHelpTheme() HelpTheme {
+	return HelpTheme{
+		Heading:     "1",
+		Command:     "36",
+		Flag:        "32",
+		Placeholder: "33",
+		Muted:       "2",
+		Example:     "36",
+	}
+}
+
+// HelpOptions replaces urfave/cli's help templates, for the
+// commander and its subcommands, with a renderer that shows the
+// usage, description, subcommands and flags grouped by category
+// (see Commander.SetCategory and FlagOptions.Category,) the defaults
+// and environment variables of flags, and examples (see
+// Commander.Examples.) The help wraps to the width of the terminal,
+// and uses colors when the output is a terminal that supports them,
+// and the NO_COLOR environment variable is not set.
+//
+// The renderer is installed as urfave/cli's HelpPrinter, which is
+// global, only while Run runs a command that uses HelpOptions, and
+// Run restores the previous printer when it returns. Help for
+// commands that do not use HelpOptions is rendered by the previous
+// printer.
+type HelpOptions struct {
+	// Theme styles the help. Defaults to DefaultHelpTheme; use
+	// &HelpTheme{} for help without styles.
+	Theme *HelpTheme
+	// Width is the width of the help when the width of the
+	// output is not known (e.g. when the output is not a
+	// terminal.) Defaults to 80.
+	Width int
+}
+
+// WithHelp renders the help of the commander, and its subcommands,
+// with the default options.
+func WithHelp() Attachment { return HelpOptions{}.Add }
+
+// Add attaches the help options to the commander. Use with the
+// Commander.With method.
+func (opts HelpOptions) Add(c *Commander) { c.help.Set(&opts) }
+
+var helpPrinter struct {
+	mtx  sync.Mutex
+	refs int
+	prev cli.HelpPrinterFunc
+}
+
+// installHelpPrinter installs the renderer as urfave/cli's
+// HelpPrinter when the command, or one of its subcommands, uses
+// HelpOptions, and returns a function that restores the previous
+// printer. Nested and concurrent calls share the renderer, which
+// is restored when the last of them restores it.
+func installHelpPrinter(app *cli.Command) func() {
+	if !usesHelpOptions(app) {
+		return func() {}
+	}
+
+	helpPrinter.mtx.Lock()
+	defer helpPrinter.mtx.Unlock()
+
+	if helpPrinter.refs == 0 {
+		next := cli.HelpPrinter
+		helpPrinter.prev = next
+		cli.HelpPrinter = func(w io.Writer, templ string, data any) {
+			if cmd, ok := data.(*cli.Command); ok {
+				for _, c := range cmd.Lineage() {
+					if opts, ok := c.Metadata[helpOptionsKey].(*HelpOptions); ok {
+						opts.render(w, cmd)
+						return
+					}
+				}
+			}
+			next(w, templ, data)
+		}
+	}
+	helpPrinter.refs++
+
+	return sync.OnceFunc(func() {
+		helpPrinter.mtx.Lock()
+		defer helpPrinter.mtx.Unlock()
+
+		if helpPrinter.refs--; helpPrinter.refs == 0 {
+			cli.HelpPrinter = helpPrinter.prev
+			helpPrinter.prev = nil
+		}
+	})
+}
+
+func usesHelpOptions(cmd *cli.Command) bool {
+	if _, ok := cmd.Metadata[helpOptionsKey]; ok {
+		return true
+	}
+	return slices.ContainsFunc(cmd.Commands, usesHelpOptions)
+}
+
+// setHelpMetadata records the help options and the examples of the
+// commander in the metadata of the command, for the renderer.
+func (c *Commander) setHelpMetadata() {
+	var examples []Example
+	c.examples.With(func(in *dt.List[Example]) { examples = irt.Collect(in.IteratorFront()) })
+
+	opts := c.help.Get()
+	if opts == nil && len(examples) == 0 {
+		return
+	}
+
+	if c.cmd.Metadata == nil {
+		c.cmd.Metadata = map[string]any{}
+	}
+	if opts != nil {
+		c.cmd.Metadata[helpOptionsKey] = opts
+	}
+	if len(examples) > 0 {
+		c.cmd.Metadata[helpExamplesKey] = examples
+	}
+}
+
+func (opts *HelpOptions) render(w io.Writer, cmd *cli.Command) {
+	term := DetectTerminal(w)
+	h := &helpWriter{
+		theme: DefaultHelpTheme(),
+		color: term.Color,
+		width: secondValueWhenFirstIsZero(term.Width, secondValueWhenFirstIsZero(opts.Width, 80)),
+	}
+	if opts.Theme != nil {
+		h.theme = *opts.Theme
+	}
+
+	title := words(h.theme.Command, cmd.FullName())
+	if cmd.Usage != "" {
+		title = append(title, span{text: "-"})
+		title = append(title, words("", cmd.Usage)...)
+	}
+	if cmd.Root() == cmd && cmd.Version != "" {
+		title = append(title, words(h.theme.Muted, "(version "+cmd.Version+")")...)
+	}
+	h.lines(0, title)
+
+	h.heading("Usage")
+	usage := words(h.theme.Command, cmd.FullName())
+	if len(cmd.VisibleFlags()) > 0 {
+		usage = append(usage, span{text: "[options]", style: h.theme.Placeholder})
+	}
+	switch {
+	case len(cmd.VisibleCommands()) > 0:
+		usage = append(usage, span{text: "<command>", style: h.theme.Placeholder})
+	case cmd.ArgsUsage != "":
+		usage = append(usage, words(h.theme.Placeholder, cmd.ArgsUsage)...)
+	default:
+		usage = append(usage, span{text: "[arguments...]", style: h.theme.Placeholder})
+	}
+	h.lines(helpIndent, usage)
+
+	if cmd.Description != "" {
+		h.heading("Description")
+		h.paragraph(helpIndent, cmd.Description)
+	}
+
+	for _, group := range groupBy(cmd.VisibleCommands(), func(sub *cli.Command) string { return sub.Category }) {
+		h.heading(secondValueWhenFirstIsZero(capitalize(group.name), "Commands"))
+		h.table(irt.Collect(irt.Convert(irt.Slice(group.items), func(sub *cli.Command) helpRow {
+			return helpRow{term: words(h.theme.Command, strings.Join(sub.Names(), ", ")), desc: words("", sub.Usage)}
+		})))
+	}
+
+	for _, group := range groupBy(cmd.VisibleFlags(), flagCategory) {
+		h.heading(secondValueWhenFirstIsZero(capitalize(group.name), "Options"))
+		h.table(irt.Collect(irt.Convert(irt.Slice(group.items), h.flagRow)))
+	}
+
+	if cmd.Root() != cmd {
+		global := slices.DeleteFunc(cmd.VisiblePersistentFlags(), func(f cli.Flag) bool {
+			return slices.Contains(cmd.Flags, f)
+		})
+		if len(global) > 0 {
+			h.heading("Global options")
+			h.table(irt.Collect(irt.Convert(irt.Slice(global), h.flagRow)))
+		}
+	}
+
+	if examples, ok := cmd.Metadata[helpExamplesKey].([]Example); ok {
+		h.heading("Examples")
+		for idx, ex := range examples {
+			if idx > 0 && ex.Description != "" {
+				h.buf.WriteByte('\n')
+			}
+			if ex.Description != "" {
+				h.paragraph(helpIndent, ex.Description)
+			}
+			h.buf.WriteString(strings.Repeat(" ", 2*helpIndent))
+			h.buf.WriteString(h.style(h.theme.Example, "$ "+ex.Command))
+			h.buf.WriteByte('\n')
+		}
+	}
+
+	_, _ = io.WriteString(w, h.buf.String())
+}
+
+func (h *helpWriter) flagRow(f cli.Flag) helpRow {
+	var placeholder, usage string
+	doc, isDoc := f.(cli.DocGenerationFlag)
+	if isDoc {
+		placeholder, usage = unquoteUsage(doc.GetUsage())
+		if placeholder == "" && doc.TakesValue() {
+			placeholder = doc.TypeName()
+		}
+	}
+
+	// short names first, as in "-v, --verbose".
+	var short, long []string
+	for _, name := range f.Names() {
+		if utf8.RuneCountInString(name) == 1 {
+			short = append(short, "-"+name)
+		} else {
+			long = append(long, "--"+name)
+		}
+	}
+	names := append(short, long...)
+
+	row := helpRow{term: words(h.theme.Flag, strings.Join(names, ", ")), desc: words("", usage)}
+	if placeholder != "" {
+		row.term = append(row.term, span{text: placeholder, style: h.theme.Placeholder})
+	}
+	if !isDoc {
+		return row
+	}
+
+	if def := flagDefault(doc); def != "" {
+		row.desc = append(row.desc, words(h.theme.Muted, "(default: "+def+")")...)
+	}
+	if rf, ok := f.(cli.RequiredFlag); ok && rf.IsRequired() {
+		row.desc = append(row.desc, span{text: "(required)", style: h.theme.Muted})
+	}
+	if env := doc.GetEnvVars(); len(env) > 0 {
+		row.desc = append(row.desc, words(h.theme.Muted, "[$"+strings.Join(env, ", $")+"]")...)
+	}
+	return row
+}
+
+// flagDefault returns the default of the flag, when the help should
+// show it: zero values are not shown.
+func flagDefault(f cli.DocGenerationFlag) string {
+	if text := f.GetDefaultText(); text != "" {
+		return text
+	}
+	if !f.TakesValue() || !f.IsDefaultVisible() {
+		return ""
+	}
+
+	switch val := f.GetValue(); val {
+	case "", `""`, "0", "0s", "[]":
+		return ""
+	default:
+		return val
+	}
+}
+
+func flagCategory(f cli.Flag) string {
+	if cf, ok := f.(cli.CategorizableFlag); ok {
+		return cf.GetCategory()
+	}
+	return ""
+}
+
+// unquoteUsage returns the placeholder of a usage string, which is
+// the first `quoted` word, and the usage without the quotes.
+func unquoteUsage(usage string) (string, string) {
+	start := strings.IndexByte(usage, '`')
+	if start < 0 {
+		return "", usage
+	}
+	end := strings.IndexByte(usage[start+1:], '`')
+	if end < 0 {
+		return "", usage
+	}
+	name := usage[start+1 : start+1+end]
+	return name, usage[:start] + name + usage[start+2+end:]
+}
+
+type helpGroup[T any] struct {
+	name  string
+	items []T
+}
+
+// groupBy groups the items by category, in the order in which the
+// categories first appear, except that items without a category are
+// always first.
+func groupBy[T any](items []T, category func(T) string) []helpGroup[T] {
+	groups := []helpGroup[T]{{}}
+	for _, item := range items {
+		name := category(item)
+		idx := slices.IndexFunc(groups, func(g helpGroup[T]) bool { return g.name == name })
+		if idx < 0 {
+			idx = len(groups)
+			groups = append(groups, helpGroup[T]{name: name})
+		}
+		groups[idx].items = append(groups[idx].items, item)
+	}
+	return slices.DeleteFunc(groups, func(g helpGroup[T]) bool { return len(g.items) == 0 })
+}
+
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+type helpWriter struct {
+	buf   strings.Builder
+	theme HelpTheme
+	color bool
+	width int
+}
+
+// span is a word of the help, and its style.
+type span struct {
+	text  string
+	style string
+}
+
+type helpRow struct {
+	term []span
+	desc []span
+}
+
+func words(style, text string) []span {
+	fields := strings.Fields(text)
+	out := make([]span, len(fields))
+	for idx, field := range fields {
+		out[idx] = span{text: field, style: style}
+	}
+	return out
+}
+
+func spanWidth(line []span) int {
+	if len(line) == 0 {
+		return 0
+	}
+	width := len(line) - 1
+	for _, s := range line {
+		width += utf8.RuneCountInString(s.text)
+	}
+	return width
+}
+
+// wrap breaks the words into lines no wider than the width, except
+// for lines with a single word that is wider.
+func wrap(ws []span, width int) [][]span {
+	width = max(width, helpMinWidth)
+
+	var lines [][]span
+	var line []span
+	var n int
+	for _, w := range ws {
+		size := utf8.RuneCountInString(w.text)
+		if len(line) > 0 && n+1+size > width {
+			lines = append(lines, line)
+			line, n = nil, 0
+		}
+		if len(line) > 0 {
+			n++
+		}
+		line = append(line, w)
+		n += size
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (h *helpWriter) style(style, text string) string {
+	if !h.color || style == "" || text == "" {
+		return text
+	}
+	return "\x1b[" + style + "m" + text + "\x1b[0m"
+}
+
+// render joins the words of a line, styling runs of words with the
+// same style together.
+func (h *helpWriter) render(line []span) string {
+	var out strings.Builder
+	for start := 0; start < len(line); {
+		end := start
+		texts := []string{}
+		for end < len(line) && line[end].style == line[start].style {
+			texts = append(texts, line[end].text)
+			end++
+		}
+		if start > 0 {
+			out.WriteByte(' ')
+		}
+		out.WriteString(h.style(line[start].style, strings.Join(texts, " ")))
+		start = end
+	}
+	return out.String()
+}
+
+func (h *helpWriter) heading(text string) {
+	h.buf.WriteByte('\n')
+	h.buf.WriteString(h.style(h.theme.Heading, text+":"))
+	h.buf.WriteByte('\n')
+}
+
+// lines writes the words, wrapped to the width, and indented.
+func (h *helpWriter) lines(indent int, ws []span) {
+	for _, line := range wrap(ws, h.width-indent) {
+		h.buf.WriteString(strings.Repeat(" ", indent))
+		h.buf.WriteString(h.render(line))
+		h.buf.WriteByte('\n')
+	}
+}
+
+// paragraph writes text, wrapping each of its lines, and preserving
+// the line breaks, blank lines, and the indentation of the lines.
+func (h *helpWriter) paragraph(indent int, text string) {
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			h.buf.WriteByte('\n')
+			continue
+		}
+		h.lines(indent+len(line)-len(trimmed), words("", trimmed))
+	}
+}
+
+// table writes rows of terms and descriptions, with the descriptions
+// aligned in a column. Descriptions of terms that are wider than the
+// column start on the next line.
+func (h *helpWriter) table(rows []helpRow) {
+	var col int
+	for _, row := range rows {
+		if n := spanWidth(row.term); n <= helpMaxColumn {
+			col = max(col, n)
+		}
+	}
+
+	pad := helpIndent + col + helpGap
+	for _, row := range rows {
+		h.buf.WriteString(strings.Repeat(" ", helpIndent))
+		h.buf.WriteString(h.render(row.term))
+
+		n := spanWidth(row.term)
+		for idx, line := range wrap(row.desc, h.width-pad) {
+			switch {
+			case idx == 0 && n <= col:
+				h.buf.WriteString(strings.Repeat(" ", col-n+helpGap))
+			default:
+				h.buf.WriteByte('\n')
+				h.buf.WriteString(strings.Repeat(" ", pad))
+			}
+			h.buf.WriteString(h.render(line))
+		}
+		h.buf.WriteByte('\n')
+	}
+}
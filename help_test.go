@@ -0,0 +1,112 @@
+package cmdr
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func TestHelp(t *testing.T) {
+	ctx := context.Background()
+	noop := func(context.Context, *cli.Command) error { return nil }
+
+	build := func(opts HelpOptions) (*Commander, *bytes.Buffer) {
+		out := &bytes.Buffer{}
+		deploy := MakeCommander().SetName("deploy").SetUsage("deploys the service").
+			Aliases("d").
+			SetDescription("Deploy builds the service, and rolls it out to the hosts of the environment, one at a time, waiting for each host to become healthy.\n\nRollouts stop at the first failure.").
+			Examples(
+				Example{Description: "Deploy to production:", Command: "tool deploy --env prod"},
+				Example{Command: "tool deploy --env dev --force"},
+			).
+			Flags(
+				FlagBuilder("dev").SetName("env", "e").SetUsage("the `ENV` to deploy to").SetEnvVars("TOOL_ENV").Flag(),
+				FlagBuilder(false).SetName("force").SetUsage("deploy even when the checks fail").Flag(),
+				FlagBuilder(0).SetName("parallel").SetUsage("hosts to deploy at once").SetCategory("rollout").Flag(),
+				FlagBuilder("").SetName("token").SetUsage("the credentials").SetRequired(true).Flag(),
+			).
+			SetAction(noop)
+		status := MakeCommander().SetName("status").SetUsage("shows the status").SetAction(noop)
+		logs := MakeCommander().SetName("logs").SetUsage("shows the logs").SetCategory("debugging").SetAction(noop)
+
+		cmd := MakeRootCommander().SetName("tool").SetUsage("manages the service").
+			SetAppOptions(AppOptions{Version: "1.2.3"}).
+			SetWriter(out).
+			With(opts.Add).
+			Flags(FlagBuilder("info").SetName("level").SetUsage("the log level").Flag()).
+			Subcommanders(deploy, logs, status)
+		return cmd, out
+	}
+
+	t.Run("Root", func(t *testing.T) {
+		printer := reflect.ValueOf(cli.HelpPrinter).Pointer()
+		cmd, out := build(HelpOptions{})
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "--help"}))
+		check.Equal(t, reflect.ValueOf(cli.HelpPrinter).Pointer(), printer)
+		text := out.String()
+		check.True(t, strings.HasPrefix(text, "tool - manages the service (version 1.2.3)\n"))
+		check.Substring(t, text, "\nUsage:\n  tool [options] <command>\n")
+		check.Substring(t, text, "\nCommands:\n  deploy, d   deploys the service\n  status      shows the status\n")
+		check.Substring(t, text, "\nDebugging:\n  logs   shows the logs\n")
+		check.Substring(t, text, "\nOptions:\n  --level string   the log level (default: \"info\")\n  -h, --help       show help\n  -v, --version    print the version\n")
+		check.True(t, strings.Index(text, "Commands:") < strings.Index(text, "Debugging:"))
+		check.NotSubstring(t, text, "\x1b[")
+	})
+	t.Run("Subcommand", func(t *testing.T) {
+		for _, args := range [][]string{{"tool", "deploy", "--help"}, {"tool", "help", "deploy"}} {
+			cmd, out := build(HelpOptions{Width: 60})
+			assert.NotError(t, Run(ctx, cmd, args))
+			text := out.String()
+			check.True(t, strings.HasPrefix(text, "tool deploy - deploys the service\n"))
+			check.Substring(t, text, "\nUsage:\n  tool deploy [options] [arguments...]\n")
+			check.Substring(t, text, "\nDescription:\n  Deploy builds the service, and rolls it out to the hosts\n  of the environment, one at a time, waiting for each host\n  to become healthy.\n\n  Rollouts stop at the first failure.\n")
+			check.Substring(t, text, "  -e, --env ENV    the ENV to deploy to (default: \"dev\")\n                   [$TOOL_ENV]\n")
+			check.Substring(t, text, "  --force          deploy even when the checks fail\n")
+			check.Substring(t, text, "  --token string   the credentials (required)\n  -h, --help       show help\n")
+			check.Substring(t, text, "\nRollout:\n  --parallel int   hosts to deploy at once\n")
+			check.Substring(t, text, "\nGlobal options:\n  --level string")
+			check.Substring(t, text, "\nExamples:\n  Deploy to production:\n    $ tool deploy --env prod\n    $ tool deploy --env dev --force\n")
+			for _, line := range strings.Split(text, "\n") {
+				check.True(t, len(line) <= 60)
+			}
+		}
+	})
+	t.Run("Fallback", func(t *testing.T) {
+		// commanders without HelpOptions use the templates of
+		// urfave/cli.
+		out := &bytes.Buffer{}
+		cmd := MakeRootCommander().SetName("tool").SetUsage("manages the service").SetWriter(out).SetAction(noop)
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "--help"}))
+		check.Substring(t, out.String(), "NAME:\n")
+	})
+	t.Run("Theme", func(t *testing.T) {
+		h := &helpWriter{theme: HelpTheme{Heading: "1", Flag: "32"}, color: true, width: 80}
+		h.heading("Options")
+		h.table([]helpRow{{term: words(h.theme.Flag, "-v, --verbose"), desc: words("", "more output")}})
+		check.Equal(t, h.buf.String(), "\n\x1b[1mOptions:\x1b[0m\n  \x1b[32m-v, --verbose\x1b[0m   more output\n")
+
+		h = &helpWriter{theme: DefaultHelpTheme(), width: 80}
+		h.heading("Options")
+		check.Equal(t, h.buf.String(), "\nOptions:\n")
+	})
+	t.Run("Wrap", func(t *testing.T) {
+		lines := wrap(words("", "aaa bbb ccc dddddddddddddddddddddddddd e"), 20)
+		check.Equal(t, len(lines), 3)
+		check.Equal(t, spanWidth(lines[0]), 11)
+		check.Equal(t, spanWidth(lines[1]), 26)
+
+		name, usage := unquoteUsage("read from `PATH`, or -")
+		check.Equal(t, name, "PATH")
+		check.Equal(t, usage, "read from PATH, or -")
+		name, usage = unquoteUsage("no `placeholder")
+		check.Equal(t, name, "")
+		check.Equal(t, usage, "no `placeholder")
+	})
+}
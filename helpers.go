@@ -2,6 +2,7 @@ package cmdr
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/tychoish/fun/erc"
 	"github.com/urfave/cli/v3"
@@ -32,6 +33,26 @@ type OperationSpec[T any] struct {
 	// Middlware is optional and makes it possible to attach T to
 	// a context for later use. Middlewares
 	Middleware func(context.Context, T) context.Context
+	// ContextKey is optional, and when set the constructed value
+	// is attached to the context with this key before the
+	// Middleware runs, so that hooks and actions on this command
+	// and its subcommands can retrieve it. Use the StoreInContext
+	// method to use the default key for T, which makes the value
+	// accessible using FromContext and MustFromContext.
+	ContextKey *ContextKey[T]
+	// Reload is optional, and when set the value is reloaded while
+	// the command runs, and delivered to the subscribers registered
+	// with SubscribeConfig and OnConfigChange. See ReloadOptions.
+	Reload *ReloadOptions
+	// Schedule is optional, and when set the Action runs on a
+	// schedule, rather than once. See ScheduleOptions.
+	Schedule *ScheduleOptions
+	// Retry is optional, and when set the Action is retried when
+	// it fails. See RetryOptions.
+	Retry *RetryOptions
+	// Confirm is optional, and when set the command asks the user
+	// to confirm before the Action runs. See ConfirmOptions.
+	Confirm *ConfirmOptions[T]
 	// Action, the core action.  may be (optionally) specified here as an Operation
 	// or directly on the command.
 	Action Operation[T]
@@ -53,6 +74,20 @@ func (s *OperationSpec[T]) SetMiddleware(mw func(context.Context, T) context.Con
 
 func (s *OperationSpec[T]) SetAction(op Operation[T]) *OperationSpec[T] { s.Action = op; return s }
 
+// SetContextKey configures the spec to attach the constructed value
+// to the context with the provided key.
+func (s *OperationSpec[T]) SetContextKey(k ContextKey[T]) *OperationSpec[T] {
+	s.ContextKey = &k
+	return s
+}
+
+// StoreInContext configures the spec to attach the constructed value
+// to the context with the default key for T, so it's available via
+// FromContext[T] and MustFromContext[T].
+func (s *OperationSpec[T]) StoreInContext() *OperationSpec[T] {
+	return s.SetContextKey(DefaultContextKey[T]())
+}
+
 func (s *OperationSpec[T]) Hooks(hook ...Operation[T]) *OperationSpec[T] {
 	s.HookOperations = append(s.HookOperations, hook...)
 	return s
@@ -77,18 +112,54 @@ func (s *OperationSpec[T]) Add(c *Commander) {
 
 		// Apply middleware immediately after the constructor so that
 		// subsequent hooks on the same commander see the updated context.
+		if s.ContextKey != nil {
+			c.setContext(s.ContextKey.Set(c.getContext(), out))
+		}
 		if s.Middleware != nil {
 			c.setContext(s.Middleware(c.getContext(), out))
 		}
+		if s.Reload != nil {
+			s.registerReload(c, cc)
+		}
 
 		return nil
 	})
 
-	if s.Action != nil {
-		c.SetAction(func(ctx context.Context, _ *cli.Command) error {
-			return s.Action(ctx, out)
-		})
+	if s.Reload != nil && s.Reload.FileFlag != "" {
+		c.AddMiddleware(s.Reload.watchMiddleware())
+	}
+
+	if s.Action == nil {
+		return
+	}
+
+	if s.Confirm != nil {
+		c.Flags(s.Confirm.flag())
+	}
+	if s.Retry != nil {
+		c.Flags(s.Retry.flags()...)
 	}
+	if s.Schedule != nil {
+		c.Flags(s.Schedule.flags()...)
+	}
+
+	c.SetAction(func(ctx context.Context, cc *cli.Command) error {
+		if s.Confirm != nil {
+			if err := s.Confirm.confirm(ctx, cc, out); err != nil {
+				return err
+			}
+		}
+
+		op := func(ctx context.Context) error { return s.Action(ctx, out) }
+		if s.Retry != nil {
+			action := op
+			op = func(ctx context.Context) error { return s.Retry.do(ctx, cc, action) }
+		}
+		if s.Schedule != nil {
+			return s.Schedule.run(ctx, cc, op)
+		}
+		return op(ctx)
+	})
 }
 
 // AddOperationSpec adds an operation to a Commander (and returns the
@@ -153,6 +224,42 @@ func Subcommander[T any](c *Commander, hook Hook[T], op Operation[T], flags ...F
 	return AddOperation(sub, hook, op, flags...)
 }
 
+// ParentHook is a Hook for subcommands that depend on the value
+// constructed by the parent command (e.g. a client or a
+// configuration object,) rather than re-parsing the parent's flags.
+type ParentHook[P any, T any] func(context.Context, P, *cli.Command) (T, error)
+
+// WithParent converts a ParentHook into a Hook which resolves the
+// parent's value from the context using the default key for P. The
+// parent command must attach its value to the context, typically by
+// using OperationSpec.StoreInContext; if the value is not set, the
+// hook returns an ErrNotSet error.
+//
+// Because the hooks of parent commands always run before the hooks
+// of their subcommands, the parent's value is always constructed
+// before the subcommand's hook runs.
+func WithParent[P any, T any](hook ParentHook[P, T]) Hook[T] {
+	return func(ctx context.Context, cc *cli.Command) (out T, err error) {
+		parent, ok := FromContext[P](ctx)
+		if !ok {
+			return out, fmt.Errorf("parent value for %q (%s): %w", cc.Name, DefaultContextKey[P](), ErrNotSet)
+		}
+		return hook(ctx, parent, cc)
+	}
+}
+
+// SubcommandWithParent uses the same semantics as Subcommander, but
+// the hook for the new subcommand receives the value constructed by
+// the parent command, as resolved by WithParent. The value
+// constructed by the subcommand's hook is also stored in the context
+// (using the default key for T) so that its subcommands can, in
+// turn, use SubcommandWithParent to depend on it.
+func SubcommandWithParent[P any, T any](c *Commander, hook ParentHook[P, T], op Operation[T], flags ...Flag) *Commander {
+	sub := MakeCommander()
+	c.Subcommanders(sub)
+	return AddOperationSpec(sub.Flags(flags...), SpecBuilder(WithParent(hook)).StoreInContext().SetAction(op))
+}
+
 // CommandOptions are the arguments to create a sub-command in a
 // commander.
 type CommandOptions[T any] struct {
@@ -0,0 +1,95 @@
+package cmdr
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/dt"
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/irt"
+)
+
+// Interceptor wraps the action of a command, making it possible to
+// run code before and after the action, or to replace it entirely:
+// to time actions, recover panics, retry operations, or translate
+// errors.
+type Interceptor func(next Action) Action
+
+// Interceptors adds interceptors to the commander. Interceptors are
+// applied when the commander is resolved into a cli.Command, and
+// are inherited by all subcommands. Interceptors only wrap actions
+// set with SetAction: commands without an action, which print their
+// help, are never intercepted. The first interceptor added to
+// the root commander is the outermost: it runs first and observes
+// the result of all other interceptors and the action.
+func (c *Commander) Interceptors(ics ...Interceptor) *Commander {
+	appendTo(&c.interceptors, ics...)
+	return c
+}
+
+// interceptorChain returns the interceptors inherited from the
+// parent commanders followed by the commander's own interceptors.
+func (c *Commander) interceptorChain() []Interceptor {
+	out := c.inherited.Get()
+	c.interceptors.With(func(in *dt.List[Interceptor]) {
+		out = append(out[:len(out):len(out)], irt.Collect(in.IteratorFront())...)
+	})
+	return out
+}
+
+// intercept applies the interceptors to the action.
+func intercept(op Action, chain []Interceptor) Action {
+	for idx := len(chain) - 1; idx >= 0; idx-- {
+		op = chain[idx](op)
+	}
+	return op
+}
+
+// RecoverInterceptor converts panics in the action (and in inner
+// interceptors) into errors rooted in ers.ErrRecoveredPanic.
+func RecoverInterceptor() Interceptor {
+	return func(next Action) Action {
+		return func(ctx context.Context, cc *cli.Command) (err error) {
+			defer func() { err = erc.Join(err, erc.ParsePanic(recover())) }()
+			return next(ctx, cc)
+		}
+	}
+}
+
+// TimingInterceptor reports the duration and outcome of every
+// action. When the reporter is nil, the timing is written to the
+// standard logger.
+func TimingInterceptor(reporter func(cmd string, dur time.Duration, err error)) Interceptor {
+	if reporter == nil {
+		reporter = func(cmd string, dur time.Duration, err error) {
+			log.Printf("command %q completed in %s [err=%v]", cmd, dur, err)
+		}
+	}
+
+	return func(next Action) Action {
+		return func(ctx context.Context, cc *cli.Command) error {
+			start := time.Now()
+			err := next(ctx, cc)
+			reporter(cc.FullName(), time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// AnnotateInterceptor prefixes errors returned by the action with
+// the full path of the command (e.g. "tool deploy"). The original
+// error remains accessible via errors.Is and errors.As.
+func AnnotateInterceptor() Interceptor {
+	return func(next Action) Action {
+		return func(ctx context.Context, cc *cli.Command) error {
+			if err := next(ctx, cc); err != nil {
+				return fmt.Errorf("%s: %w", cc.FullName(), err)
+			}
+			return nil
+		}
+	}
+}
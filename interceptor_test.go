@@ -0,0 +1,83 @@
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+	"github.com/tychoish/fun/ers"
+)
+
+func TestInterceptors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	trace := func(out *[]string, name string) Interceptor {
+		return func(next Action) Action {
+			return func(ctx context.Context, cc *cli.Command) error {
+				*out = append(*out, name+":before")
+				err := next(ctx, cc)
+				*out = append(*out, name+":after")
+				return err
+			}
+		}
+	}
+
+	t.Run("OrderAndInheritance", func(t *testing.T) {
+		var order []string
+		sub := MakeCommander().SetName("sub").
+			Interceptors(trace(&order, "child")).
+			SetAction(func(context.Context, *cli.Command) error { order = append(order, "action"); return nil })
+		cmd := MakeRootCommander().
+			Interceptors(trace(&order, "outer"), trace(&order, "inner")).
+			Subcommanders(sub)
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "sub"}))
+		assert.Equal(t, strings.Join(order, ","),
+			"outer:before,inner:before,child:before,action,child:after,inner:after,outer:after")
+	})
+	t.Run("Recover", func(t *testing.T) {
+		cmd := MakeRootCommander().
+			Interceptors(RecoverInterceptor()).
+			SetAction(func(context.Context, *cli.Command) error { panic("kip") })
+
+		err := Run(ctx, cmd, []string{"tool"})
+		assert.ErrorIs(t, err, ers.ErrRecoveredPanic)
+		assert.Substring(t, err.Error(), "kip")
+	})
+	t.Run("Timing", func(t *testing.T) {
+		var (
+			name string
+			dur  time.Duration
+			err  error
+		)
+		sentinel := errors.New("kip")
+		sub := MakeCommander().SetName("sub").SetAction(func(context.Context, *cli.Command) error {
+			time.Sleep(time.Millisecond)
+			return sentinel
+		})
+		cmd := MakeRootCommander().SetName("tool").
+			Interceptors(TimingInterceptor(func(cmd string, d time.Duration, e error) { name, dur, err = cmd, d, e })).
+			Subcommanders(sub)
+
+		assert.ErrorIs(t, Run(ctx, cmd, []string{"tool", "sub"}), sentinel)
+		check.Equal(t, name, "tool sub")
+		check.True(t, dur >= time.Millisecond)
+		check.ErrorIs(t, err, sentinel)
+	})
+	t.Run("Annotate", func(t *testing.T) {
+		sentinel := errors.New("kip")
+		sub := MakeCommander().SetName("sub").SetAction(func(context.Context, *cli.Command) error { return sentinel })
+		cmd := MakeRootCommander().SetName("tool").Interceptors(AnnotateInterceptor()).Subcommanders(sub)
+
+		err := Run(ctx, cmd, []string{"tool", "sub"})
+		assert.ErrorIs(t, err, sentinel)
+		assert.True(t, strings.HasPrefix(err.Error(), "tool sub: "))
+	})
+}
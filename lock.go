@@ -0,0 +1,188 @@
+package cmdr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/ers"
+	"github.com/tychoish/fun/srv"
+)
+
+// ErrLocked is at the root of the errors returned when another
+// process holds the lock of a command that uses LockOptions.
+const ErrLocked = ers.Error("command is already running")
+
+// errWouldBlock is returned by tryLock when another process holds
+// the lock.
+const errWouldBlock = ers.Error("lock is held")
+
+// LockOptions ensures that only one instance of a command runs at a
+// time, using an advisory lock on a file (flock(2)). The lock is
+// acquired by a fallible middleware (see AddMiddleware) that runs
+// before the other middleware, and is released, and the pidfile
+// removed, by the srv package's cleanup when the command returns,
+// including when the hooks, middleware, or action fail or
+// panic. The operating system releases the lock if the process
+// exits without releasing it.
+//
+// File locks are not supported on all platforms: where they are not,
+// acquiring the lock returns an errors.ErrUnsupported error.
+type LockOptions struct {
+	// Path is the path of the lock file. Defaults to
+	// "<name>.lock", in os.TempDir(), where <name> is the name of
+	// the command.
+	Path string
+	// PIDFile is optional, and when set the process writes its
+	// PID to this file after it acquires the lock. The PID is
+	// always written to the lock file.
+	PIDFile string
+	// Timeout is how long to wait for the lock when another
+	// process holds it. When zero, the command fails immediately.
+	Timeout time.Duration
+	// PollInterval is the time between attempts to acquire the
+	// lock while waiting. Defaults to 100 milliseconds.
+	PollInterval time.Duration
+}
+
+// SingleInstance ensures that only one instance of the command runs
+// at a time, failing immediately if another instance holds the lock
+// file.
+func SingleInstance(path string) Attachment { return LockOptions{Path: path}.Add }
+
+// Add attaches the lock to the commander. Use with the Commander.With
+// method.
+func (opts LockOptions) Add(c *Commander) {
+	opts.PollInterval = secondValueWhenFirstIsZero(opts.PollInterval, 100*time.Millisecond)
+
+	name := "lock"
+	if opts.Path != "" {
+		name = "lock " + opts.Path
+	}
+
+	c.holdLock(name, func(ctx context.Context, cc *cli.Command) (*fileLock, error) {
+		path := opts.Path
+		if path == "" {
+			path = filepath.Join(os.TempDir(), cc.Name+".lock")
+		}
+		return acquireLock(ctx, path, opts)
+	})
+}
+
+// holdLock acquires a lock, when acquire returns one, in a fallible
+// middleware, named name, that runs before the other middleware,
+// and registers the release of the lock with the srv package's
+// cleanup as soon as it is acquired.
+func (c *Commander) holdLock(name string, acquire func(context.Context, *cli.Command) (*fileLock, error)) {
+	c.AddMiddleware(MiddlewareOptions{
+		Name:     name,
+		Priority: math.MinInt,
+		Middleware: func(ctx context.Context, cc *cli.Command) (context.Context, error) {
+			lock, err := acquire(ctx, cc)
+			if err != nil || lock == nil {
+				return ctx, err
+			}
+
+			if !srv.HasCleanup(ctx) {
+				return ctx, erc.Join(fmt.Errorf("releasing %s: srv cleanup %w", name, ErrNotDefined), lock.release())
+			}
+
+			srv.AddCleanup(ctx, func(context.Context) error { return lock.release() })
+			return ctx, nil
+		},
+	})
+}
+
+type fileLock struct {
+	file    *os.File
+	pidfile string
+	once    sync.Once
+	err     error
+}
+
+func acquireLock(ctx context.Context, path string, opts LockOptions) (*fileLock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file: %w", err)
+	}
+
+	var timer <-chan time.Time
+	if opts.Timeout > 0 {
+		timer = time.After(opts.Timeout)
+	}
+
+	for {
+		err = tryLock(file)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errWouldBlock) {
+			return nil, erc.Join(fmt.Errorf("locking %q: %w", path, err), file.Close())
+		}
+		if timer == nil {
+			return nil, erc.Join(lockHeldError(path, file), file.Close())
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, erc.Join(fmt.Errorf("waiting for lock %q: %w", path, ctx.Err()), file.Close())
+		case <-timer:
+			return nil, erc.Join(fmt.Errorf("waited %s: %w", opts.Timeout, lockHeldError(path, file)), file.Close())
+		case <-time.After(opts.PollInterval):
+		}
+	}
+
+	lock := &fileLock{file: file, pidfile: opts.PIDFile}
+	pid := []byte(strconv.Itoa(os.Getpid()) + "\n")
+
+	var ec erc.Collector
+	ec.Push(file.Truncate(0))
+	_, err = file.WriteAt(pid, 0)
+	ec.Push(err)
+	if opts.PIDFile != "" {
+		ec.Push(os.WriteFile(opts.PIDFile, pid, 0o644))
+	}
+
+	if err := ec.Resolve(); err != nil {
+		return nil, erc.Join(fmt.Errorf("writing pid for lock %q: %w", path, err), lock.release())
+	}
+	return lock, nil
+}
+
+// lockHeldError reports the PID of the process that holds the lock,
+// when the lock file contains it.
+func lockHeldError(path string, file *os.File) error {
+	data := make([]byte, 32)
+	n, _ := file.ReadAt(data, 0)
+	if pid := string(bytes.TrimSpace(data[:n])); pid != "" {
+		return fmt.Errorf("lock %q held by pid %s: %w", path, pid, ErrLocked)
+	}
+	return fmt.Errorf("lock %q: %w", path, ErrLocked)
+}
+
+// release removes the pidfile and releases the lock. Release is safe
+// to call more than once.
+func (l *fileLock) release() error {
+	l.once.Do(func() {
+		var ec erc.Collector
+		if l.pidfile != "" {
+			if err := os.Remove(l.pidfile); !errors.Is(err, os.ErrNotExist) {
+				ec.Push(err)
+			}
+		}
+		ec.Push(unlock(l.file))
+		ec.Push(l.file.Close())
+		l.err = ec.Resolve()
+	})
+	return l.err
+}
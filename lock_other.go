@@ -0,0 +1,11 @@
+//go:build !unix
+
+package cmdr
+
+import (
+	"errors"
+	"os"
+)
+
+func tryLock(*os.File) error { return errors.ErrUnsupported }
+func unlock(*os.File) error  { return nil }
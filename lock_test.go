@@ -0,0 +1,161 @@
+//go:build unix
+
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func TestLock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("Held", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "tool.lock")
+		pidfile := filepath.Join(dir, "tool.pid")
+
+		cmd := MakeRootCommander().SetName("tool").
+			With(LockOptions{Path: path, PIDFile: pidfile}.Add).
+			SetAction(func(ctx context.Context, _ *cli.Command) error {
+				data, err := os.ReadFile(pidfile)
+				assert.NotError(t, err)
+				check.Equal(t, string(data), strconv.Itoa(os.Getpid())+"\n")
+
+				_, err = acquireLock(ctx, path, LockOptions{})
+				assert.ErrorIs(t, err, ErrLocked)
+				check.Substring(t, err.Error(), "held by pid "+strconv.Itoa(os.Getpid()))
+				return nil
+			})
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+
+		_, err := os.Stat(pidfile)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		lock, err := acquireLock(ctx, path, LockOptions{})
+		assert.NotError(t, err)
+		assert.NotError(t, lock.release())
+		assert.NotError(t, lock.release())
+	})
+	t.Run("RepeatedRuns", func(t *testing.T) {
+		dir := t.TempDir()
+		paths := []string{filepath.Join(dir, "a.lock"), filepath.Join(dir, "b.lock")}
+
+		cmd := MakeRootCommander().SetName("tool").
+			With(SingleInstance(paths[0])).
+			With(SingleInstance(paths[1])).
+			SetAction(func(ctx context.Context, _ *cli.Command) error {
+				for _, path := range paths {
+					_, err := acquireLock(ctx, path, LockOptions{})
+					assert.ErrorIs(t, err, ErrLocked)
+				}
+				return nil
+			})
+
+		for range 2 {
+			assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+			for _, path := range paths {
+				lock, err := acquireLock(ctx, path, LockOptions{})
+				assert.NotError(t, err)
+				assert.NotError(t, lock.release())
+			}
+		}
+	})
+	t.Run("DefaultPath", func(t *testing.T) {
+		t.Setenv("TMPDIR", t.TempDir())
+		cmd := MakeRootCommander().SetName("tool").With(SingleInstance("")).
+			SetAction(func(ctx context.Context, _ *cli.Command) error {
+				_, err := acquireLock(ctx, filepath.Join(os.TempDir(), "tool.lock"), LockOptions{})
+				assert.ErrorIs(t, err, ErrLocked)
+				return nil
+			})
+		assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+	})
+	t.Run("FailFast", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tool.lock")
+		lock, err := acquireLock(ctx, path, LockOptions{})
+		assert.NotError(t, err)
+		defer lock.release()
+
+		ran := false
+		cmd := MakeRootCommander().SetName("tool").With(SingleInstance(path)).
+			SetAction(func(context.Context, *cli.Command) error { ran = true; return nil })
+
+		assert.ErrorIs(t, Run(ctx, cmd, []string{"tool"}), ErrLocked)
+		check.True(t, !ran)
+	})
+	t.Run("Wait", func(t *testing.T) {
+		t.Run("Timeout", func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tool.lock")
+			lock, err := acquireLock(ctx, path, LockOptions{})
+			assert.NotError(t, err)
+			defer lock.release()
+
+			cmd := MakeRootCommander().SetName("tool").
+				With(LockOptions{Path: path, Timeout: 50 * time.Millisecond, PollInterval: 5 * time.Millisecond}.Add).
+				SetAction(func(context.Context, *cli.Command) error { return nil })
+
+			err = Run(ctx, cmd, []string{"tool"})
+			assert.ErrorIs(t, err, ErrLocked)
+			check.Substring(t, err.Error(), "waited 50ms")
+		})
+		t.Run("Acquired", func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tool.lock")
+			lock, err := acquireLock(ctx, path, LockOptions{})
+			assert.NotError(t, err)
+			time.AfterFunc(20*time.Millisecond, func() { _ = lock.release() })
+
+			ran := false
+			cmd := MakeRootCommander().SetName("tool").
+				With(LockOptions{Path: path, Timeout: 5 * time.Second, PollInterval: 5 * time.Millisecond}.Add).
+				SetAction(func(context.Context, *cli.Command) error { ran = true; return nil })
+
+			assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+			check.True(t, ran)
+		})
+	})
+	t.Run("ReleasedOnError", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tool.lock")
+		cmd := MakeRootCommander().SetName("tool").
+			With(SingleInstance(path)).
+			Hooks(func(context.Context, *cli.Command) error { return errors.New("hook failed") }).
+			SetAction(func(context.Context, *cli.Command) error { return nil })
+
+		assert.Error(t, Run(ctx, cmd, []string{"tool"}))
+
+		lock, err := acquireLock(ctx, path, LockOptions{})
+		assert.NotError(t, err)
+		assert.NotError(t, lock.release())
+	})
+	t.Run("ReleasedOnPanic", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("TMPDIR", dir)
+		paths := []string{filepath.Join(dir, "tool.lock"), filepath.Join(dir, "other.lock")}
+
+		cmd := MakeRootCommander().SetName("tool").
+			With(LockOptions{}.Add).
+			With(LockOptions{Path: paths[1]}.Add).
+			Hooks(func(context.Context, *cli.Command) error { panic("hook crashed") }).
+			SetAction(func(context.Context, *cli.Command) error { return nil })
+
+		assert.ErrorIs(t, Run(ctx, cmd, []string{"tool"}), ErrCrashed)
+
+		for _, path := range paths {
+			lock, err := acquireLock(ctx, path, LockOptions{})
+			assert.NotError(t, err)
+			assert.NotError(t, lock.release())
+		}
+	})
+}
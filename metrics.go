@@ -0,0 +1,301 @@
+package cmdr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel/attribute"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"github.com/tychoish/fun/dt"
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/srv"
+)
+
+var metricsCtxKey = MakeContextKey[*Metrics]("cmdr.metrics")
+
+// phaseBuckets are the upper bounds, in seconds, of the buckets of
+// the histogram of phase durations.
+var phaseBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// Metrics records the metrics of a command with an OpenTelemetry
+// MeterProvider. All methods are safe for concurrent use.
+//
+// Use the MetricsOptions attachment to record metrics for a command,
+// and MetricsFromContext to access the Metrics from hooks,
+// middleware, and operations. Record custom metrics with instruments
+// from the MeterProvider.
+type Metrics struct {
+	provider metric.MeterProvider
+	gatherer prometheus.Gatherer
+	handler  http.Handler
+
+	invocations metric.Int64Counter
+	phases      metric.Float64Histogram
+	restarts    metric.Int64Counter
+}
+
+// NewMetrics records metrics with the provider. When the provider is
+// nil, the metrics are exported to a new Prometheus registry, which
+// the Metrics serve over HTTP, and render with WriteTo.
+func NewMetrics(provider metric.MeterProvider) (*Metrics, error) {
+	m := &Metrics{provider: provider, handler: http.NotFoundHandler()}
+	if provider == nil {
+		reg := prometheus.NewRegistry()
+		exp, err := otelprom.New(otelprom.WithRegisterer(reg))
+		if err != nil {
+			return nil, fmt.Errorf("prometheus exporter: %w", err)
+		}
+		m.provider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(exp))
+		m.gatherer = reg
+		m.handler = promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+	}
+
+	meter := m.provider.Meter(tracerName)
+
+	var ec erc.Collector
+	var err error
+	m.invocations, err = meter.Int64Counter("cmdr.command.invocations",
+		metric.WithDescription("number of command invocations"),
+		metric.WithUnit("{invocation}"),
+	)
+	ec.Push(err)
+	m.phases, err = meter.Float64Histogram("cmdr.phase.duration",
+		metric.WithDescription("duration of the phases of command execution"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(phaseBuckets...),
+	)
+	ec.Push(err)
+	m.restarts, err = meter.Int64Counter("cmdr.service.restarts",
+		metric.WithDescription("number of times services restarted"),
+		metric.WithUnit("{restart}"),
+	)
+	ec.Push(err)
+
+	if err := ec.Resolve(); err != nil {
+		return nil, fmt.Errorf("metrics: %w", err)
+	}
+	return m, nil
+}
+
+// MetricsFromContext returns the metrics attached to the context by
+// the MetricsOptions attachment.
+func MetricsFromContext(ctx context.Context) (*Metrics, bool) { return metricsCtxKey.Get(ctx) }
+
+// MeterProvider returns the provider that records the metrics, for
+// custom instruments.
+func (m *Metrics) MeterProvider() metric.MeterProvider { return m.provider }
+
+// ServiceRestarted records that the service restarted. Services
+// declared with ServiceOptions record their restarts automatically.
+func (m *Metrics) ServiceRestarted(ctx context.Context, service string) {
+	m.restarts.Add(ctx, 1, metric.WithAttributes(attribute.String("service", service)))
+}
+
+func (m *Metrics) invoked(ctx context.Context, command string, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	m.invocations.Add(ctx, 1, metric.WithAttributes(attribute.String("command", command), attribute.String("status", status)))
+}
+
+func (m *Metrics) observePhase(ctx context.Context, command, phase string, dur time.Duration) {
+	m.phases.Record(ctx, dur.Seconds(), metric.WithAttributes(attribute.String("command", command), attribute.String("phase", phase)))
+}
+
+// WriteTo renders the metrics, in the Prometheus text exposition
+// format, to the writer. Metrics recorded with a provider passed to
+// NewMetrics are exported by the provider, and are not rendered.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	if m.gatherer == nil {
+		return 0, nil
+	}
+
+	families, err := m.gatherer.Gather()
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for _, fam := range families {
+		n, err := expfmt.MetricFamilyToText(w, model.EscapeMetricFamily(fam, model.UnderscoreEscaping))
+		count += int64(n)
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// ServeHTTP implements http.Handler, serving the metrics in the
+// Prometheus exposition formats.
+func (m *Metrics) ServeHTTP(rw http.ResponseWriter, r *http.Request) { m.handler.ServeHTTP(rw, r) }
+
+// MetricsOptions configures the metrics for a command. The command
+// records, with the OpenTelemetry metrics API:
+//
+//	cmdr.command.invocations{command,status}
+//	cmdr.phase.duration{command,phase}      (seconds)
+//	cmdr.service.restarts{service}
+//
+// where phases are hooks, middleware, and the action. Run records
+// the invocation when the command returns, so that invocations that
+// fail before the action runs (e.g. in a hook, or because the flags
+// are not valid) are counted as errors.
+//
+// By default, the metrics are exported in the Prometheus format (as
+// cmdr_command_invocations_total, cmdr_phase_duration_seconds, and
+// cmdr_service_restarts_total), and when the metrics flag is set,
+// the command starts an HTTP server, as a service managed by the
+// srv.Orchestrator, that serves them. The Metrics are accessible
+// from the context with MetricsFromContext, for custom metrics,
+// even when the metrics flag is not set. Attach metrics to the root
+// commander.
+type MetricsOptions struct {
+	// MeterProvider, when set, records the metrics, and its
+	// readers export them: the commander does not have the
+	// metrics flag. When nil, the commander exports the metrics
+	// in the Prometheus format.
+	MeterProvider metric.MeterProvider
+	// FlagName is the name of the flag that specifies the
+	// address of the HTTP server. Defaults to "metrics-addr".
+	FlagName string
+	// Path is the path of the metrics endpoint. Defaults to
+	// "/metrics".
+	Path string
+}
+
+// WithMetrics enables metrics, using the default options, for the
+// commander.
+func WithMetrics() Attachment { return MetricsOptions{}.Add }
+
+// Add attaches metrics to the commander. Use with the Commander.With
+// method.
+func (opts MetricsOptions) Add(c *Commander) {
+	opts.FlagName = secondValueWhenFirstIsZero(opts.FlagName, "metrics-addr")
+	opts.Path = secondValueWhenFirstIsZero(opts.Path, "/metrics")
+	m, err := NewMetrics(opts.MeterProvider)
+	if opts.MeterProvider == nil {
+		c.Flags(MakeFlag(&FlagOptions[string]{
+			Name:  opts.FlagName,
+			Usage: "serve metrics over HTTP on this address (e.g. localhost:9090)",
+		}))
+	}
+
+	c.hook.With(func(in *dt.List[Action]) {
+		in.PushFront(func(context.Context, *cli.Command) error {
+			if err != nil {
+				return err
+			}
+			c.setContext(metricsCtxKey.Set(c.getContext(), m))
+			return nil
+		})
+	})
+
+	// the interceptor records the duration of the actions of the
+	// commander and its subcommands, and Run records the outcome
+	// of every invocation, including invocations that fail before
+	// the action runs.
+	c.Interceptors(func(next Action) Action {
+		return func(ctx context.Context, cc *cli.Command) error {
+			start := time.Now()
+			err := next(ctx, cc)
+			if m != nil {
+				m.observePhase(ctx, cc.FullName(), "action", time.Since(start))
+			}
+			return err
+		}
+	})
+	c.onComplete(func(ctx context.Context, command string, err error) {
+		if m != nil {
+			m.invoked(ctx, command, err)
+		}
+	})
+
+	c.AddMiddleware(MiddlewareOptions{
+		Name:     "metrics",
+		Priority: math.MinInt,
+		Middleware: func(ctx context.Context, cc *cli.Command) (context.Context, error) {
+			if m == nil || m.gatherer == nil {
+				return ctx, nil
+			}
+			addr := cc.String(opts.FlagName)
+			if addr == "" {
+				return ctx, nil
+			}
+
+			mux := http.NewServeMux()
+			mux.Handle(opts.Path, m)
+			return ctx, startHTTPService(ctx, "metrics", addr, mux, nil)
+		},
+	})
+}
+
+// startHTTPService listens on the address, so that errors are
+// reported immediately, and then serves the handler in a service
+// managed by the orchestrator in the context. Requests have the
+// values, but not the lifetime, of the context. The drain function,
+// if set, runs when shutdown begins, before the server stops.
+func startHTTPService(ctx context.Context, name, addr string, handler http.Handler, drain func()) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("%s service: %w", name, err)
+	}
+
+	hs := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return context.WithoutCancel(ctx) },
+	}
+
+	return srv.GetOrchestrator(ctx).Add(&srv.Service{
+		Name: name,
+		Run: func(context.Context) error {
+			if err := hs.Serve(ln); err != http.ErrServerClosed {
+				return err
+			}
+			return nil
+		},
+		Shutdown: func() error {
+			if drain != nil {
+				drain()
+			}
+			sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return hs.Shutdown(sctx)
+		},
+	})
+}
+
+// startPhase starts a phase (a hook or middleware) of the execution
+// of a command, when tracing or metrics are enabled. The
+// returned function ends the phase, recording the error, if any.
+func startPhase(ctx context.Context, phase string, cc *cli.Command) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, end := startSpan(ctx, phase, attribute.String("cmdr.command", cc.FullName()))
+	return ctx, func(err error) {
+		end(err)
+		observeDuration(ctx, phase, cc.FullName(), time.Since(start))
+	}
+}
+
+// observeDuration records the duration of a phase of the command's
+// execution, when metrics are enabled.
+func observeDuration(ctx context.Context, phase, command string, dur time.Duration) {
+	if m, ok := metricsCtxKey.Get(ctx); ok {
+		m.observePhase(ctx, command, phase, dur)
+	}
+}
@@ -0,0 +1,167 @@
+package cmdr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+// metricValue collects the metrics from the reader, and returns the
+// value of the counter, or the count of the histogram, with the name
+// and attributes.
+func metricValue(t *testing.T, reader sdkmetric.Reader, name string, attrs ...attribute.KeyValue) float64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	assert.NotError(t, reader.Collect(context.Background(), &rm))
+
+	want := attribute.NewSet(attrs...)
+	for _, sm := range rm.ScopeMetrics {
+		for _, md := range sm.Metrics {
+			if md.Name != name {
+				continue
+			}
+			switch data := md.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					if dp.Attributes.Equals(&want) {
+						return float64(dp.Value)
+					}
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					if dp.Attributes.Equals(&want) {
+						return float64(dp.Count)
+					}
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("Prometheus", func(t *testing.T) {
+		m, err := NewMetrics(nil)
+		assert.NotError(t, err)
+		m.ServiceRestarted(ctx, "worker")
+		m.ServiceRestarted(ctx, "worker")
+		jobs, err := m.MeterProvider().Meter("test").Int64Counter("jobs")
+		assert.NotError(t, err)
+		jobs.Add(ctx, 3, metric.WithAttributes(attribute.String("kind", "a")))
+
+		buf := &bytes.Buffer{}
+		n, err := m.WriteTo(buf)
+		assert.NotError(t, err)
+		check.Equal(t, int(n), buf.Len())
+		check.Substring(t, buf.String(), "# TYPE cmdr_service_restarts_total counter\n")
+		check.Substring(t, buf.String(), `service="worker"} 2`)
+		check.Substring(t, buf.String(), `kind="a"`)
+
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		check.Equal(t, rec.Code, http.StatusOK)
+		check.Substring(t, rec.Body.String(), "cmdr_service_restarts_total")
+	})
+	t.Run("Provider", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		m, err := NewMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+		assert.NotError(t, err)
+		m.ServiceRestarted(ctx, "worker")
+		check.Equal(t, metricValue(t, reader, "cmdr.service.restarts", attribute.String("service", "worker")), 1)
+
+		n, err := m.WriteTo(&bytes.Buffer{})
+		assert.NotError(t, err)
+		check.Equal(t, n, 0)
+
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		check.Equal(t, rec.Code, http.StatusNotFound)
+	})
+	t.Run("Commander", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		cmd := MakeRootCommander().SetName("tool").
+			With(MetricsOptions{MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))}.Add).
+			Hooks(func(ctx context.Context, _ *cli.Command) error {
+				_, ok := MetricsFromContext(ctx)
+				check.True(t, ok)
+				return nil
+			}).
+			Subcommanders(
+				MakeCommander().SetName("ok").SetAction(func(ctx context.Context, _ *cli.Command) error {
+					m, ok := MetricsFromContext(ctx)
+					check.True(t, ok)
+					custom, err := m.MeterProvider().Meter("test").Int64Counter("custom")
+					assert.NotError(t, err)
+					custom.Add(ctx, 1)
+					return nil
+				}),
+				MakeCommander().SetName("fail").SetAction(func(context.Context, *cli.Command) error {
+					return errors.New("fail")
+				}),
+				MakeCommander().SetName("hook").
+					Hooks(func(context.Context, *cli.Command) error { return errors.New("hook") }).
+					SetAction(func(context.Context, *cli.Command) error { return nil }),
+			)
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "ok"}))
+		assert.Error(t, Run(ctx, cmd, []string{"tool", "fail"}))
+		assert.Error(t, Run(ctx, cmd, []string{"tool", "--metrics-addr", "localhost:0", "ok"}))
+		assert.Error(t, Run(ctx, cmd, []string{"tool", "hook"}))
+
+		invocations := func(command, status string) float64 {
+			return metricValue(t, reader, "cmdr.command.invocations", attribute.String("command", command), attribute.String("status", status))
+		}
+		phase := func(command, phase string) float64 {
+			return metricValue(t, reader, "cmdr.phase.duration", attribute.String("command", command), attribute.String("phase", phase))
+		}
+		check.Equal(t, invocations("tool ok", "success"), 1)
+		check.Equal(t, invocations("tool fail", "error"), 1)
+		check.Equal(t, invocations("tool hook", "error"), 1)
+		check.Equal(t, invocations("tool", "error"), 1)
+		check.Equal(t, metricValue(t, reader, "custom"), 1)
+		check.Equal(t, phase("tool ok", "action"), 1)
+		check.Equal(t, phase("tool", "hook 1"), 3)
+	})
+	t.Run("Endpoint", func(t *testing.T) {
+		addr := freeAddr(t)
+
+		var body string
+		cmd := MakeRootCommander().SetName("tool").With(WithMetrics()).
+			SetAction(func(ctx context.Context, _ *cli.Command) (err error) {
+				_, body, err = httpGet("http://" + addr + "/metrics")
+				return err
+			})
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "--metrics-addr", addr}))
+		check.Substring(t, body, "# TYPE cmdr_phase_duration_seconds histogram\n")
+		check.Substring(t, body, `phase="middleware metrics"} 1`)
+	})
+	t.Run("EndpointListenError", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NotError(t, err)
+		defer ln.Close()
+
+		cmd := MakeRootCommander().SetName("tool").With(WithMetrics()).
+			SetAction(func(context.Context, *cli.Command) error { return nil })
+
+		err = Run(ctx, cmd, []string{"tool", "--metrics-addr", ln.Addr().String()})
+		assert.Error(t, err)
+		check.Substring(t, err.Error(), "metrics service")
+	})
+}
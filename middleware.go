@@ -0,0 +1,149 @@
+package cmdr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/dt"
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/irt"
+)
+
+// FallibleMiddleware is a Middleware that has access to the command
+// and can return an error: when it errors, the context is not
+// modified, the error is collected with the errors from the hooks,
+// and the command's action does not run.
+type FallibleMiddleware func(context.Context, *cli.Command) (context.Context, error)
+
+// MiddlewareOptions defines a fallible middleware and its position
+// relative to the other fallible middleware on a commander.
+//
+// Middleware are ordered so that all Before and After constraints
+// are satisfied; among middleware that are unconstrained relative to
+// each other, middleware with a lower Priority run first, and
+// middleware with equal priority run in the order they were
+// added. Constraints that refer to names that are not defined on the
+// commander are ignored, while cyclic constraints produce an
+// ErrDependencyCycle error when the command runs.
+type MiddlewareOptions struct {
+	// Name identifies the middleware for the Before and After
+	// constraints of other middleware and in error messages.
+	Name string
+	// Priority orders unconstrained middleware: lower values run
+	// first.
+	Priority int
+	// Before lists the names of middleware that must run after
+	// this middleware.
+	Before []string
+	// After lists the names of middleware that must run before
+	// this middleware.
+	After []string
+	// Middleware is required.
+	Middleware FallibleMiddleware
+}
+
+// Add attaches the middleware to the commander. Use with the
+// Commander.With method.
+func (opts MiddlewareOptions) Add(c *Commander) { c.AddMiddleware(opts) }
+
+// AddMiddleware adds fallible middleware to the commander. Fallible
+// middleware run during the same phase as the commander's other
+// Middleware, after all of the middleware added with the Middleware
+// method.
+func (c *Commander) AddMiddleware(opts ...MiddlewareOptions) *Commander {
+	for idx := range opts {
+		erc.InvariantOk(opts[idx].Middleware != nil, "middleware must not be nil")
+	}
+	appendTo(&c.ordered, opts...)
+	return c
+}
+
+func (c *Commander) runOrderedMiddleware(cc *cli.Command) error {
+	var mws []MiddlewareOptions
+	c.ordered.With(func(in *dt.List[MiddlewareOptions]) { mws = irt.Collect(in.IteratorFront()) })
+	if len(mws) == 0 {
+		return nil
+	}
+
+	order, err := orderMiddleware(mws)
+	if err != nil {
+		return err
+	}
+
+	var ec erc.Collector
+	for _, mw := range order {
+		parent := c.getContext()
+		ctx, end := startPhase(parent, "middleware "+mw.Name, cc)
+		ctx, err := mw.Middleware(ctx, cc)
+		end(err)
+		if err != nil {
+			ec.Wrapf(err, "middleware %q", mw.Name)
+			continue
+		}
+		c.setContext(endPhase(ctx, parent))
+	}
+	return ec.Resolve()
+}
+
+// orderMiddleware sorts the middleware topologically, choosing the
+// available middleware with the lowest priority (and then the
+// earliest position) at every step.
+func orderMiddleware(mws []MiddlewareOptions) ([]MiddlewareOptions, error) {
+	byName := map[string][]int{}
+	for idx, mw := range mws {
+		if mw.Name != "" {
+			byName[mw.Name] = append(byName[mw.Name], idx)
+		}
+	}
+
+	// edges[i] holds the middleware that must run after i.
+	edges := make([][]int, len(mws))
+	indegree := make([]int, len(mws))
+	addEdge := func(from, to int) { edges[from] = append(edges[from], to); indegree[to]++ }
+	for idx, mw := range mws {
+		for _, name := range mw.Before {
+			for _, other := range byName[name] {
+				addEdge(idx, other)
+			}
+		}
+		for _, name := range mw.After {
+			for _, other := range byName[name] {
+				addEdge(other, idx)
+			}
+		}
+	}
+
+	out := make([]MiddlewareOptions, 0, len(mws))
+	done := make([]bool, len(mws))
+	for len(out) < len(mws) {
+		next := -1
+		for idx := range mws {
+			if done[idx] || indegree[idx] > 0 {
+				continue
+			}
+			if next < 0 || mws[idx].Priority < mws[next].Priority {
+				next = idx
+			}
+		}
+
+		if next < 0 {
+			var names []string
+			for idx := range mws {
+				if !done[idx] {
+					names = append(names, fmt.Sprintf("%q", mws[idx].Name))
+				}
+			}
+			return nil, fmt.Errorf("ordering middleware %v: %w", names, ErrDependencyCycle)
+		}
+
+		done[next] = true
+		out = append(out, mws[next])
+		for _, to := range edges[next] {
+			indegree[to]--
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,88 @@
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func TestFallibleMiddleware(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	record := func(out *[]string, name string) FallibleMiddleware {
+		return func(ctx context.Context, cc *cli.Command) (context.Context, error) {
+			*out = append(*out, name)
+			return ctx, nil
+		}
+	}
+
+	t.Run("Ordering", func(t *testing.T) {
+		var order []string
+		cmd := MakeRootCommander().
+			AddMiddleware(
+				MiddlewareOptions{Name: "db", After: []string{"log"}, Middleware: record(&order, "db")},
+				MiddlewareOptions{Name: "log", Middleware: record(&order, "log")},
+				MiddlewareOptions{Name: "early", Priority: -1, Middleware: record(&order, "early")},
+				MiddlewareOptions{Name: "cache", Before: []string{"db", "undefined"}, Priority: 10, Middleware: record(&order, "cache")},
+			).
+			SetAction(func(context.Context, *cli.Command) error { return nil })
+
+		assert.NotError(t, Run(ctx, cmd, []string{t.Name()}))
+		assert.Equal(t, strings.Join(order, ","), "early,log,cache,db")
+	})
+	t.Run("ContextPropagates", func(t *testing.T) {
+		key := MakeContextKey[string]("mw")
+		cmd := MakeRootCommander().
+			With(MiddlewareOptions{
+				Name: "value",
+				Middleware: func(ctx context.Context, cc *cli.Command) (context.Context, error) {
+					return key.Set(ctx, "kip"), nil
+				},
+			}.Add).
+			SetAction(func(ctx context.Context, cc *cli.Command) error {
+				check.Equal(t, key.MustGet(ctx), "kip")
+				return nil
+			})
+		assert.NotError(t, Run(ctx, cmd, []string{t.Name()}))
+	})
+	t.Run("ErrorAbortsAction", func(t *testing.T) {
+		cmd := MakeRootCommander().
+			AddMiddleware(MiddlewareOptions{
+				Name: "open",
+				Middleware: func(ctx context.Context, cc *cli.Command) (context.Context, error) {
+					return nil, errors.New("kip")
+				},
+			}).
+			SetAction(func(context.Context, *cli.Command) error {
+				t.Error("should not run")
+				return nil
+			})
+
+		err := Run(ctx, cmd, []string{t.Name()})
+		assert.Error(t, err)
+		assert.Substring(t, err.Error(), "open")
+		assert.Substring(t, err.Error(), "kip")
+	})
+	t.Run("Cycle", func(t *testing.T) {
+		var order []string
+		cmd := MakeRootCommander().
+			AddMiddleware(
+				MiddlewareOptions{Name: "one", After: []string{"two"}, Middleware: record(&order, "one")},
+				MiddlewareOptions{Name: "two", After: []string{"one"}, Middleware: record(&order, "two")},
+			).
+			SetAction(func(context.Context, *cli.Command) error { return nil })
+
+		assert.ErrorIs(t, Run(ctx, cmd, []string{t.Name()}), ErrDependencyCycle)
+		assert.Equal(t, len(order), 0)
+	})
+	t.Run("NilPanics", func(t *testing.T) {
+		assert.Panic(t, func() { MakeCommander().AddMiddleware(MiddlewareOptions{Name: "nil"}) })
+	})
+}
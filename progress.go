@@ -0,0 +1,317 @@
+package cmdr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/ers"
+	"github.com/tychoish/fun/srv"
+)
+
+var progressCtxKey = MakeContextKey[*Progress]("cmdr.progress")
+
+// errProgressStopped marks the tasks that were not done when the
+// progress stopped.
+const errProgressStopped = ers.Error("stopped")
+
+// ProgressFromContext returns the progress reporter attached to the
+// context by ProgressOptions, if any.
+func ProgressFromContext(ctx context.Context) (*Progress, bool) { return progressCtxKey.Get(ctx) }
+
+// Progress reports the progress of the tasks of a command: bars,
+// for tasks with a known amount of work, and spinners, for tasks
+// without one. Any number of tasks may run at once.
+//
+// When the output is a terminal, Progress redraws all of the running
+// tasks, in place, at a regular interval, and prints the final line
+// of each task when it completes. Otherwise, Progress writes a line
+// for each running task periodically, and a line for each task when
+// it completes. When quiet, Progress writes nothing, but tasks are
+// still safe to use.
+//
+// Use ProgressOptions to attach a Progress to a command, and
+// ProgressFromContext to access it. The methods of Progress and
+// ProgressTask are safe for concurrent use.
+type Progress struct {
+	out         io.Writer
+	terminal    bool
+	quiet       bool
+	interval    time.Duration
+	logInterval time.Duration
+
+	mtx     sync.Mutex
+	tasks   []*ProgressTask
+	lines   int
+	frame   int
+	lastLog time.Time
+
+	once sync.Once
+	stop chan struct{}
+	done chan struct{}
+}
+
+// ProgressTask is a single task of a Progress. Create tasks with
+// Progress.Bar and Progress.Spinner, and call Done when the task
+// completes.
+type ProgressTask struct {
+	name    string
+	started time.Time
+	total   atomic.Int64
+	current atomic.Int64
+
+	mtx      sync.Mutex
+	finished bool
+	err      error
+	elapsed  time.Duration
+}
+
+func newProgress(out io.Writer, terminal, quiet bool, interval, logInterval time.Duration) *Progress {
+	return &Progress{
+		out:         out,
+		terminal:    terminal,
+		quiet:       quiet,
+		interval:    interval,
+		logInterval: logInterval,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Bar starts a task with a known amount of work, the total. Use Add
+// or Set to report the work completed.
+func (p *Progress) Bar(name string, total int64) *ProgressTask {
+	t := p.task(name)
+	t.total.Store(total)
+	return t
+}
+
+// Spinner starts a task with an unknown amount of work. Add and Set
+// may still report a count of the work completed.
+func (p *Progress) Spinner(name string) *ProgressTask { return p.task(name) }
+
+func (p *Progress) task(name string) *ProgressTask {
+	t := &ProgressTask{name: name, started: time.Now()}
+	if !p.quiet {
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+		p.tasks = append(p.tasks, t)
+	}
+	return t
+}
+
+// Add records n more units of completed work.
+func (t *ProgressTask) Add(n int64) { t.current.Add(n) }
+
+// Set records the amount of completed work.
+func (t *ProgressTask) Set(n int64) { t.current.Store(n) }
+
+// SetTotal changes the total amount of work of the task. A task with
+// a total of zero is a spinner.
+func (t *ProgressTask) SetTotal(total int64) { t.total.Store(total) }
+
+// Done completes the task, successfully when the error is nil. Only
+// the first call to Done has an effect.
+func (t *ProgressTask) Done(err error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if !t.finished {
+		t.finished = true
+		t.err = err
+		t.elapsed = time.Since(t.started)
+	}
+}
+
+func (t *ProgressTask) isDone() bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.finished
+}
+
+var spinnerFrames = []string{"-", "\\", "|", "/"}
+
+const progressBarWidth = 30
+
+// line renders the current state of the task. The bar and spinner
+// are only rendered on terminals.
+func (t *ProgressTask) line(frame int, terminal bool) string {
+	t.mtx.Lock()
+	finished, err, elapsed := t.finished, t.err, t.elapsed
+	t.mtx.Unlock()
+
+	current, total := t.current.Load(), t.total.Load()
+	var count string
+	switch {
+	case total > 0:
+		count = fmt.Sprintf("%d/%d (%d%%)", current, total, min(100, 100*max(current, 0)/total))
+	case current > 0:
+		count = fmt.Sprint(current)
+	}
+
+	switch {
+	case finished && err == errProgressStopped:
+		return strings.TrimSpace(fmt.Sprintf("%s: stopped after %s %s", t.name, elapsed.Round(time.Millisecond), count))
+	case finished && err != nil:
+		return strings.TrimSpace(fmt.Sprintf("%s: failed after %s: %v", t.name, elapsed.Round(time.Millisecond), err))
+	case finished:
+		return strings.TrimSpace(fmt.Sprintf("%s: done in %s %s", t.name, elapsed.Round(time.Millisecond), count))
+	case !terminal:
+		return strings.TrimSpace(fmt.Sprintf("%s: %s %s", t.name, time.Since(t.started).Round(time.Second), count))
+	case total > 0:
+		filled := int(min(progressBarWidth, progressBarWidth*max(current, 0)/total))
+		return fmt.Sprintf("%s [%s%s] %s", t.name, strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled), count)
+	default:
+		return strings.TrimSpace(fmt.Sprintf("%s %s %s", spinnerFrames[frame%len(spinnerFrames)], t.name, count))
+	}
+}
+
+// start renders the tasks until the progress stops.
+func (p *Progress) start() {
+	if p.quiet {
+		close(p.done)
+		return
+	}
+	p.lastLog = time.Now()
+	if p.terminal {
+		// hide the cursor while rendering.
+		_, _ = io.WriteString(p.out, "\x1b[?25l")
+	}
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.render(false)
+			}
+		}
+	}()
+}
+
+// Stop renders the final state of all of the tasks, marking the
+// tasks that are not done as stopped, and restores the terminal.
+// Stop is safe to call more than once.
+func (p *Progress) Stop() {
+	p.once.Do(func() {
+		close(p.stop)
+		<-p.done
+		if p.quiet {
+			return
+		}
+
+		p.mtx.Lock()
+		for _, t := range p.tasks {
+			t.Done(errProgressStopped)
+		}
+		p.mtx.Unlock()
+
+		p.render(true)
+		if p.terminal {
+			_, _ = io.WriteString(p.out, "\x1b[?25h")
+		}
+	})
+}
+
+func (p *Progress) render(final bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	buf := &bytes.Buffer{}
+	if p.terminal && p.lines > 0 {
+		// move to the start of the first line of the running
+		// tasks, and clear to the end of the screen.
+		fmt.Fprintf(buf, "\x1b[%dF\x1b[J", p.lines)
+	}
+
+	running := p.tasks[:0]
+	for _, t := range p.tasks {
+		if !t.isDone() {
+			running = append(running, t)
+			continue
+		}
+		buf.WriteString(t.line(p.frame, p.terminal))
+		buf.WriteByte('\n')
+	}
+	p.tasks = running
+
+	switch {
+	case p.terminal:
+		p.frame++
+		for _, t := range running {
+			buf.WriteString(t.line(p.frame, true))
+			buf.WriteByte('\n')
+		}
+		p.lines = len(running)
+	case !final && len(running) > 0 && time.Since(p.lastLog) >= p.logInterval:
+		p.lastLog = time.Now()
+		for _, t := range running {
+			buf.WriteString(t.line(p.frame, false))
+			buf.WriteByte('\n')
+		}
+	}
+
+	if buf.Len() > 0 {
+		_, _ = p.out.Write(buf.Bytes())
+	}
+}
+
+// ProgressOptions attaches a Progress to the command, which actions
+// access with ProgressFromContext. The progress renders to the
+// command's ErrWriter (standard error, by default,) and stops, with
+// the terminal restored, during the srv package's cleanup, including
+// when the command is canceled. The quiet flag disables the output.
+type ProgressOptions struct {
+	// Interval is how often the progress redraws on terminals.
+	// Defaults to 100 milliseconds.
+	Interval time.Duration
+	// LogInterval is how often the progress writes the state of
+	// the running tasks, when the output is not a terminal.
+	// Defaults to 10 seconds.
+	LogInterval time.Duration
+	// QuietFlag is the name of the flag that disables the output.
+	// Defaults to "quiet".
+	QuietFlag string
+}
+
+// WithProgress attaches a progress reporter, using the default
+// options, to the commander.
+func WithProgress() Attachment { return ProgressOptions{}.Add }
+
+// Add attaches the progress to the commander. Use with the
+// Commander.With method.
+func (opts ProgressOptions) Add(c *Commander) {
+	opts.Interval = secondValueWhenFirstIsZero(opts.Interval, 100*time.Millisecond)
+	opts.LogInterval = secondValueWhenFirstIsZero(opts.LogInterval, 10*time.Second)
+	opts.QuietFlag = secondValueWhenFirstIsZero(opts.QuietFlag, "quiet")
+
+	c.Flags(MakeFlag(&FlagOptions[bool]{
+		Name:  opts.QuietFlag,
+		Usage: "do not report progress",
+	}))
+
+	c.AddMiddleware(MiddlewareOptions{
+		Name: "progress",
+		Middleware: func(ctx context.Context, cc *cli.Command) (context.Context, error) {
+			if !srv.HasCleanup(ctx) {
+				return ctx, fmt.Errorf("stopping progress: srv cleanup %w", ErrNotDefined)
+			}
+
+			out := StreamsFromContext(ctx).Err
+			p := newProgress(out, DetectTerminal(out).TTY, cc.Bool(opts.QuietFlag), opts.Interval, opts.LogInterval)
+			p.start()
+			srv.AddCleanup(ctx, func(context.Context) error { p.Stop(); return nil })
+			return progressCtxKey.Set(ctx, p), nil
+		},
+	})
+}
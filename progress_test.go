@@ -0,0 +1,153 @@
+package cmdr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func TestProgress(t *testing.T) {
+	t.Run("Lines", func(t *testing.T) {
+		bar := (&Progress{}).Bar("fetch", 4)
+		check.Equal(t, bar.line(0, true), "fetch [                              ] 0/4 (0%)")
+		bar.Add(1)
+		check.Equal(t, bar.line(0, true), "fetch [=======                       ] 1/4 (25%)")
+		check.Equal(t, bar.line(0, false), "fetch: 0s 1/4 (25%)")
+		bar.Set(8)
+		check.Substring(t, bar.line(0, true), "8/4 (100%)")
+
+		spin := (&Progress{}).Spinner("wait")
+		check.Equal(t, spin.line(0, true), "- wait")
+		check.Equal(t, spin.line(1, true), "\\ wait")
+		spin.Add(3)
+		check.Equal(t, spin.line(2, true), "| wait 3")
+
+		spin.Done(errors.New("broken"))
+		check.Substring(t, spin.line(0, true), "wait: failed after ")
+		check.Substring(t, spin.line(0, true), ": broken")
+		spin.Done(nil)
+		check.Substring(t, spin.line(0, true), ": broken")
+
+		bar.SetTotal(0)
+		bar.Done(nil)
+		check.Substring(t, bar.line(0, true), "fetch: done in ")
+	})
+	t.Run("Terminal", func(t *testing.T) {
+		out := &syncBuffer{}
+		p := newProgress(out, true, false, time.Millisecond, time.Hour)
+		p.start()
+
+		one := p.Bar("one", 2)
+		p.Spinner("two")
+		one.Add(1)
+		waitFor(t, func() bool { return strings.Contains(out.String(), "1/2 (50%)") })
+		check.Substring(t, out.String(), "two")
+
+		one.Add(1)
+		one.Done(nil)
+		waitFor(t, func() bool { return strings.Contains(out.String(), "one: done in") })
+		p.Stop()
+		p.Stop()
+
+		text := out.String()
+		check.True(t, strings.HasPrefix(text, "\x1b[?25l"))
+		check.True(t, strings.HasSuffix(text, "\n\x1b[?25h"))
+		check.Substring(t, text, "\x1b[2F\x1b[J")
+		check.Equal(t, strings.Count(text, "one: done in"), 1)
+		check.Equal(t, strings.Count(text, "two: stopped after"), 1)
+	})
+	t.Run("Log", func(t *testing.T) {
+		out := &syncBuffer{}
+		p := newProgress(out, false, false, time.Millisecond, 5*time.Millisecond)
+		p.start()
+
+		bar := p.Bar("fetch", 10)
+		bar.Add(5)
+		waitFor(t, func() bool { return strings.Count(out.String(), "fetch: ") >= 2 })
+		bar.Done(nil)
+		p.Stop()
+
+		text := out.String()
+		check.NotSubstring(t, text, "\x1b")
+		check.Substring(t, text, "5/10 (50%)\n")
+		check.Equal(t, strings.Count(text, "fetch: done in"), 1)
+		check.True(t, strings.HasSuffix(text, "5/10 (50%)\n"))
+	})
+	t.Run("Quiet", func(t *testing.T) {
+		out := &syncBuffer{}
+		p := newProgress(out, true, true, time.Millisecond, time.Millisecond)
+		p.start()
+		p.Bar("fetch", 10).Add(10)
+		p.Spinner("wait").Done(nil)
+		time.Sleep(5 * time.Millisecond)
+		p.Stop()
+		check.Equal(t, out.String(), "")
+	})
+	t.Run("Command", func(t *testing.T) {
+		run := func(args ...string) (string, error) {
+			out := &syncBuffer{}
+			cmd := MakeRootCommander().SetName("tool").
+				With(ProgressOptions{Interval: time.Millisecond, LogInterval: time.Millisecond}.Add).
+				SetAction(func(ctx context.Context, _ *cli.Command) error {
+					p, ok := ProgressFromContext(ctx)
+					assert.True(t, ok)
+					task := p.Spinner("work")
+					time.Sleep(10 * time.Millisecond)
+					task.Add(1)
+					return nil
+				})
+			cmd.cmd.ErrWriter = out
+			err := Run(context.Background(), cmd, append([]string{"tool"}, args...))
+			return out.String(), err
+		}
+
+		out, err := run()
+		assert.NotError(t, err)
+		check.Substring(t, out, "work: ")
+		check.Substring(t, out, "work: stopped after ")
+		check.True(t, strings.HasSuffix(out, " 1\n"))
+
+		out, err = run("--quiet")
+		assert.NotError(t, err)
+		check.Equal(t, out, "")
+	})
+	t.Run("FanOut", func(t *testing.T) {
+		out := &syncBuffer{}
+		cmd := MakeRootCommander().SetName("tool").
+			With(ProgressOptions{Interval: time.Hour}.Add).
+			With(FanOut(func(context.Context, string) error { return nil }))
+		cmd.cmd.ErrWriter = out
+
+		assert.NotError(t, Run(context.Background(), cmd, []string{"tool", "a", "b", "c"}))
+		check.Substring(t, out.String(), "tool: done in ")
+		check.Substring(t, out.String(), "3/3 (100%)")
+	})
+}
+
+type syncBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return bytes.Clone(b.buf.Bytes())
+}
+
+func (b *syncBuffer) String() string { return string(b.Bytes()) }
@@ -0,0 +1,305 @@
+package cmdr
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+
+	"github.com/tychoish/fun/dt"
+)
+
+var prompterCtxKey = MakeContextKey[*Prompter]("cmdr.prompter")
+
+// nonInteractiveCtxKey marks commands with PromptOptions that do not
+// prompt, because the input is not a terminal, or the no-input flag
+// is set.
+var nonInteractiveCtxKey = MakeContextKey[bool]("cmdr.non-interactive")
+
+// PrompterFromContext returns the prompter attached to the context
+// by PromptOptions. There is only a prompter when the command is
+// interactive: the input is a terminal (or scripted,) and the
+// no-input flag is not set.
+func PrompterFromContext(ctx context.Context) (*Prompter, bool) { return prompterCtxKey.Get(ctx) }
+
+// Prompter asks the user questions, writing the questions to its
+// output and reading the answers, one per line, from its input.
+// Prompters are not safe for concurrent use.
+type Prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	// fd is the file descriptor of the input, when the input is
+	// a terminal, so that passwords are read without echo, and
+	// is otherwise -1.
+	fd int
+}
+
+// NewPrompter constructs a prompter. When the input is a terminal,
+// Password reads without echoing the input.
+func NewPrompter(in io.Reader, out io.Writer) *Prompter {
+	p := &Prompter{in: bufio.NewReader(in), out: out, fd: -1}
+	if fd, ok := terminalFd(in); ok {
+		p.fd = fd
+	}
+	return p
+}
+
+func (p *Prompter) readLine() (string, error) {
+	line, err := p.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("reading answer: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Text asks a question, and returns the answer, or the default, when
+// the answer is empty.
+func (p *Prompter) Text(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+
+	answer, err := p.readLine()
+	if err != nil {
+		return "", err
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// Password asks a question, and returns the answer, which is not
+// echoed when the input is a terminal.
+func (p *Prompter) Password(question string) (string, error) {
+	fmt.Fprintf(p.out, "%s: ", question)
+	if p.fd < 0 {
+		return p.readLine()
+	}
+
+	answer, err := term.ReadPassword(p.fd)
+	fmt.Fprintln(p.out)
+	if err != nil {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+	return string(answer), nil
+}
+
+// Confirm asks a yes or no question, and returns the answer, or the
+// default, when the answer is empty. Confirm asks again until the
+// answer is valid.
+func (p *Prompter) Confirm(question string, def bool) (bool, error) {
+	choices := "y/N"
+	if def {
+		choices = "Y/n"
+	}
+
+	for {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, choices)
+		answer, err := p.readLine()
+		if err != nil {
+			return false, err
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(p.out, "please answer yes or no")
+	}
+}
+
+// Select asks the user to choose one of the choices, by number or by
+// name, and returns the choice, or the default, when the answer is
+// empty. Select asks again until the answer is valid.
+func (p *Prompter) Select(question string, choices []string, def string) (string, error) {
+	if len(choices) == 0 {
+		return "", fmt.Errorf("choices for %q: %w", question, ErrNotSpecified)
+	}
+
+	fmt.Fprintf(p.out, "%s:\n", question)
+	for idx, choice := range choices {
+		fmt.Fprintf(p.out, "  %d) %s\n", idx+1, choice)
+	}
+
+	for {
+		answer, err := p.Text(fmt.Sprintf("choose 1-%d", len(choices)), def)
+		if err != nil {
+			return "", err
+		}
+
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(choices) {
+			return choices[n-1], nil
+		}
+		for _, choice := range choices {
+			if answer == choice {
+				return choice, nil
+			}
+		}
+		fmt.Fprintf(p.out, "%q is not one of the choices\n", answer)
+	}
+}
+
+// promptFlag asks for the value of a flag that is not set, and sets
+// the flag, asking again while the answer is not valid for the flag.
+// Empty answers leave optional flags unset.
+func promptFlag[T FlagTypes](p *Prompter, cc *cli.Command, opts *FlagOptions[T]) error {
+	if cc.IsSet(opts.Name) {
+		return nil
+	}
+
+	for {
+		answer, err := promptAnswer(p, opts)
+		if err != nil {
+			return fmt.Errorf("prompting for --%s: %w", opts.Name, err)
+		}
+
+		switch {
+		case answer == "" && !opts.Required:
+			return nil
+		case answer == "":
+			err = errors.New("a value is required")
+		default:
+			if err = cc.Set(opts.Name, answer); err == nil {
+				err = opts.doValidate(GetFlag[T](cc, opts.Name))
+			}
+			switch any(opts.Default).(type) {
+			case []string, []int, []int64:
+				// slice flags accumulate values, so
+				// the flag cannot be set again.
+				if err != nil {
+					return fmt.Errorf("invalid value %q for --%s: %w", answer, opts.Name, err)
+				}
+			}
+		}
+		if err == nil {
+			return nil
+		}
+		fmt.Fprintf(p.out, "invalid value for --%s: %v\n", opts.Name, err)
+	}
+}
+
+func promptAnswer[T FlagTypes](p *Prompter, opts *FlagOptions[T]) (string, error) {
+	if def, ok := any(opts.Default).(bool); ok {
+		answer, err := p.Confirm(opts.Prompt, def)
+		return strconv.FormatBool(answer), err
+	}
+
+	var def string
+	switch dval := any(opts.Default).(type) {
+	case string:
+		def = dval
+	case []string, []int, []int64:
+	default:
+		var zero T
+		if any(opts.Default) != any(zero) {
+			def = fmt.Sprint(dval)
+		}
+	}
+
+	switch {
+	case len(opts.Choices) > 0:
+		return p.Select(opts.Prompt, opts.Choices, def)
+	case opts.Secret:
+		return p.Password(opts.Prompt)
+	default:
+		return p.Text(opts.Prompt, def)
+	}
+}
+
+// PromptOptions makes commands ask the user, interactively, for the
+// values of flags that are not set, when the flags define a Prompt
+// (see FlagOptions.) The commander asks during the Before phase of
+// the command, before the hooks run, and only when the input is a
+// terminal (or scripted) and the no-input flag is not set. When the
+// command is not interactive, required flags that are not set are
+// errors, as usual.
+//
+// Attach PromptOptions to the root commander. Actions may also ask
+// questions with the Prompter from PrompterFromContext.
+type PromptOptions struct {
+	// In is the input. Defaults to the input of the command (see
+	// Streams), and prompts only when the input is a terminal.
+	// When set, the commander always prompts, which makes it
+	// possible to script the answers (e.g. in tests.)
+	In io.Reader
+	// Out is where questions are written. Defaults to the error
+	// output of the command.
+	Out io.Writer
+	// NoInputFlag is the name of the flag that disables prompts.
+	// Defaults to "no-input".
+	NoInputFlag string
+}
+
+// WithPrompts makes the commander prompt for missing flags, using the
+// default options.
+func WithPrompts() Attachment { return PromptOptions{}.Add }
+
+// Add attaches the prompts to the commander. Use with the
+// Commander.With method.
+func (opts PromptOptions) Add(c *Commander) {
+	opts.NoInputFlag = secondValueWhenFirstIsZero(opts.NoInputFlag, "no-input")
+
+	c.Flags(MakeFlag(&FlagOptions[bool]{
+		Name:  opts.NoInputFlag,
+		Usage: "never prompt for input",
+	}))
+	c.prompts.Set(&opts)
+}
+
+// attachPrompter attaches a prompter to the context, when the
+// commander has prompts, and the command is interactive.
+func (c *Commander) attachPrompter(ctx context.Context, cc *cli.Command) context.Context {
+	opts := c.prompts.Get()
+	switch {
+	case opts == nil || prompterCtxKey.Has(ctx):
+		return ctx
+	case cc.Bool(opts.NoInputFlag):
+		return nonInteractiveCtxKey.Set(ctx, true)
+	}
+
+	streams := StreamsFromContext(ctx)
+	in := opts.In
+	if in == nil {
+		if _, ok := terminalFd(streams.In); !ok {
+			return nonInteractiveCtxKey.Set(ctx, true)
+		}
+		in = streams.In
+	}
+
+	return prompterCtxKey.Set(ctx, NewPrompter(in, secondValueWhenFirstIsZero(opts.Out, streams.Err)))
+}
+
+// runPrompts asks for the values of the commander's flags that have
+// prompts and are not set, when the command is interactive.
+func (c *Commander) runPrompts(ctx context.Context, cc *cli.Command) error {
+	p, ok := PrompterFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	var err error
+	c.flags.With(func(flags *dt.List[Flag]) {
+		for flag := range flags.IteratorFront() {
+			if flag.prompt == nil {
+				continue
+			}
+			if err = flag.prompt(p, cc); err != nil {
+				return
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,196 @@
+package cmdr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func TestPrompter(t *testing.T) {
+	prompter := func(input string) (*Prompter, *bytes.Buffer) {
+		out := &bytes.Buffer{}
+		return NewPrompter(strings.NewReader(input), out), out
+	}
+
+	t.Run("Text", func(t *testing.T) {
+		p, out := prompter("alice\n\n")
+		answer, err := p.Text("name", "bob")
+		assert.NotError(t, err)
+		check.Equal(t, answer, "alice")
+		answer, err = p.Text("name", "bob")
+		assert.NotError(t, err)
+		check.Equal(t, answer, "bob")
+		check.Equal(t, out.String(), "name [bob]: name [bob]: ")
+
+		_, err = p.Text("name", "")
+		assert.ErrorIs(t, err, io.EOF)
+	})
+	t.Run("FinalLine", func(t *testing.T) {
+		p, _ := prompter("alice")
+		answer, err := p.Text("name", "")
+		assert.NotError(t, err)
+		check.Equal(t, answer, "alice")
+	})
+	t.Run("Password", func(t *testing.T) {
+		p, out := prompter("s3cret \n")
+		answer, err := p.Password("password")
+		assert.NotError(t, err)
+		check.Equal(t, answer, "s3cret ")
+		check.Equal(t, out.String(), "password: ")
+	})
+	t.Run("Confirm", func(t *testing.T) {
+		p, out := prompter("maybe\nYes\n\nn\n")
+		ok, err := p.Confirm("continue", false)
+		assert.NotError(t, err)
+		check.True(t, ok)
+		check.Substring(t, out.String(), "continue [y/N]: please answer yes or no\n")
+
+		ok, err = p.Confirm("continue", true)
+		assert.NotError(t, err)
+		check.True(t, ok)
+		check.Substring(t, out.String(), "continue [Y/n]: ")
+
+		ok, err = p.Confirm("continue", true)
+		assert.NotError(t, err)
+		check.True(t, !ok)
+	})
+	t.Run("Select", func(t *testing.T) {
+		p, out := prompter("4\nprod\n2\n\n")
+		choices := []string{"dev", "staging", "prod"}
+		answer, err := p.Select("environment", choices, "")
+		assert.NotError(t, err)
+		check.Equal(t, answer, "prod")
+		check.Substring(t, out.String(), "environment:\n  1) dev\n  2) staging\n  3) prod\nchoose 1-3: ")
+		check.Substring(t, out.String(), `"4" is not one of the choices`)
+
+		answer, err = p.Select("environment", choices, "")
+		assert.NotError(t, err)
+		check.Equal(t, answer, "staging")
+
+		answer, err = p.Select("environment", choices, "dev")
+		assert.NotError(t, err)
+		check.Equal(t, answer, "dev")
+
+		_, err = p.Select("environment", nil, "")
+		assert.ErrorIs(t, err, ErrNotSpecified)
+	})
+}
+
+func TestPrompts(t *testing.T) {
+	ctx := context.Background()
+
+	type result struct {
+		hooked   string
+		name     string
+		password string
+		env      string
+		force    bool
+		count    int
+	}
+
+	build := func(input string, out io.Writer, res *result) *Commander {
+		sub := MakeCommander().SetName("deploy").
+			Flags(
+				FlagBuilder("dev").SetName("env").SetPrompt("environment").SetChoices("dev", "prod").Flag(),
+				FlagBuilder(false).SetName("force").SetPrompt("force the deploy").Flag(),
+				FlagBuilder(0).SetName("count").SetPrompt("how many").
+					SetValidate(func(n int) error {
+						if n < 0 {
+							return errors.New("must not be negative")
+						}
+						return nil
+					}).Flag(),
+			).
+			SetAction(func(ctx context.Context, cc *cli.Command) error {
+				res.name = cc.String("name")
+				res.password = cc.String("password")
+				res.env = cc.String("env")
+				res.force = cc.Bool("force")
+				res.count = cc.Int("count")
+				return nil
+			})
+
+		return MakeRootCommander().SetName("tool").
+			With(PromptOptions{In: strings.NewReader(input), Out: out}.Add).
+			Flags(
+				FlagBuilder("").SetName("name").SetRequired(true).SetPrompt("your name").Flag(),
+				FlagBuilder("").SetName("password").SetSecret(true).SetPrompt("password").Flag(),
+			).
+			Hooks(func(ctx context.Context, cc *cli.Command) error {
+				// hooks see the answers.
+				res.hooked = cc.String("name")
+				return nil
+			}).
+			Subcommanders(sub)
+	}
+
+	t.Run("Answers", func(t *testing.T) {
+		var res result
+		out := &bytes.Buffer{}
+		input := strings.Join([]string{"", "alice", "hunter2", "2", "y", "x", "-1", "3"}, "\n") + "\n"
+		assert.NotError(t, Run(ctx, build(input, out, &res), []string{"tool", "deploy"}))
+
+		check.Equal(t, res, result{hooked: "alice", name: "alice", password: "hunter2", env: "prod", force: true, count: 3})
+		check.Substring(t, out.String(), "invalid value for --name: a value is required\n")
+		check.Substring(t, out.String(), "invalid value for --count: ")
+		check.Substring(t, out.String(), "invalid value for --count: must not be negative\n")
+	})
+	t.Run("Defaults", func(t *testing.T) {
+		var res result
+		input := strings.Join([]string{"alice", "", "", "", ""}, "\n") + "\n"
+		assert.NotError(t, Run(ctx, build(input, &bytes.Buffer{}, &res), []string{"tool", "deploy"}))
+		check.Equal(t, res, result{hooked: "alice", name: "alice", env: "dev"})
+	})
+	t.Run("SetFlags", func(t *testing.T) {
+		var res result
+		out := &bytes.Buffer{}
+		assert.NotError(t, Run(ctx, build("", out, &res), []string{
+			"tool", "--name", "bob", "--password", "pw", "deploy", "--env", "dev", "--force", "--count", "1",
+		}))
+		check.Equal(t, res, result{hooked: "bob", name: "bob", password: "pw", env: "dev", force: true, count: 1})
+		check.Equal(t, out.String(), "")
+	})
+	t.Run("NoInput", func(t *testing.T) {
+		var res result
+		out := &bytes.Buffer{}
+		err := Run(ctx, build("alice\n", out, &res), []string{"tool", "--no-input", "deploy"})
+		check.Error(t, err)
+		check.Substring(t, err.Error(), "name")
+		check.Equal(t, out.String(), "")
+	})
+	t.Run("EOF", func(t *testing.T) {
+		var res result
+		err := Run(ctx, build("", &bytes.Buffer{}, &res), []string{"tool", "deploy"})
+		assert.ErrorIs(t, err, io.EOF)
+		check.Substring(t, err.Error(), "prompting for --name")
+	})
+	t.Run("Context", func(t *testing.T) {
+		cmd := MakeRootCommander().SetName("tool").
+			With(PromptOptions{In: strings.NewReader("y\n"), Out: io.Discard}.Add).
+			SetAction(func(ctx context.Context, _ *cli.Command) error {
+				p, ok := PrompterFromContext(ctx)
+				assert.True(t, ok)
+				ok, err := p.Confirm("really", false)
+				assert.True(t, ok)
+				return err
+			})
+		assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+
+		cmd = MakeRootCommander().SetName("tool").
+			With(PromptOptions{In: strings.NewReader("y\n"), Out: io.Discard}.Add).
+			SetAction(func(ctx context.Context, _ *cli.Command) error {
+				_, ok := PrompterFromContext(ctx)
+				check.True(t, !ok)
+				return nil
+			})
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "--no-input"}))
+	})
+}
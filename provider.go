@@ -0,0 +1,190 @@
+package cmdr
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/ers"
+	"github.com/tychoish/fun/srv"
+)
+
+// ErrDependencyCycle is returned when a provider depends, directly or
+// indirectly, on the type it provides, or when the ordering
+// constraints of middleware are cyclic.
+const ErrDependencyCycle = ers.Error("dependency cycle")
+
+// Provider constructs a value for use by the hooks and operations of
+// a command. Register providers with the Provide function, and
+// access their values with Resolve.
+type Provider[T any] func(context.Context) (T, error)
+
+type provider struct {
+	make    func(context.Context) (any, error)
+	cleanup func(any) error
+}
+
+// Provide registers a provider for values of type T on the
+// commander, with an optional cleanup function. Typically providers
+// are registered on the root Commander, which makes them available to
+// all subcommands; registering a provider for the same type on a
+// subcommand shadows the parent's provider for that subcommand.
+// Registering a second provider for a type on the same commander
+// replaces the first.
+//
+// Providers are lazy: they run the first time that Resolve is called
+// for their type during an invocation of the command, and their
+// values are memoized for the remainder of that invocation. Providers
+// may themselves call Resolve to depend on other types.
+//
+// Cleanup functions, for the values that were constructed, run with
+// the srv package's cleanup service (e.g. srv.AddCleanup) in the
+// reverse order of construction. The cleanup service is only
+// available for commands that descend from a root Commander.
+func Provide[T any](c *Commander, p Provider[T], cleanup ...func(T) error) *Commander {
+	c.providers.Store(reflect.TypeFor[T](), provider{
+		make: func(ctx context.Context) (any, error) { return p(ctx) },
+		cleanup: func(in any) error {
+			var ec erc.Collector
+			for _, fn := range cleanup {
+				ec.Push(fn(in.(T)))
+			}
+			return ec.Resolve()
+		},
+	})
+	return c
+}
+
+// Resolve returns the value produced by the provider for the type T
+// registered on this command or one of its parents. If no provider
+// is registered, Resolve returns an ErrNotDefined error.
+func Resolve[T any](ctx context.Context) (out T, err error) {
+	typ := reflect.TypeFor[T]()
+
+	cont, ok := ctx.Value(containerCtxKey{}).(*container)
+	if !ok {
+		return out, fmt.Errorf("provider for %s: %w", typ, ErrNotDefined)
+	}
+
+	val, err := cont.resolve(ctx, typ)
+	if err != nil {
+		return out, err
+	}
+
+	out, _ = val.(T)
+	return out, nil
+}
+
+// MustResolve returns the value produced by the provider for the
+// type T, and panics with an invariant violation if the provider is
+// not registered or returns an error.
+func MustResolve[T any](ctx context.Context) T { return erc.Must(Resolve[T](ctx)) }
+
+type (
+	containerCtxKey struct{}
+	resolvingCtxKey struct{}
+)
+
+type instance struct {
+	once sync.Once
+	val  any
+	err  error
+}
+
+// container holds the providers and the memoized values for a single
+// invocation of a command.
+type container struct {
+	owner      *Commander
+	parent     *container
+	providers  map[reflect.Type]provider
+	registered atomic.Bool
+
+	mtx       sync.Mutex
+	instances map[reflect.Type]*instance
+	cleanups  []func() error
+}
+
+func (c *Commander) attachProviders(ctx context.Context) context.Context {
+	if c.providers.Len() == 0 {
+		return ctx
+	}
+
+	cont := &container{
+		owner:     c,
+		providers: map[reflect.Type]provider{},
+		instances: map[reflect.Type]*instance{},
+	}
+	cont.parent, _ = ctx.Value(containerCtxKey{}).(*container)
+
+	for typ, p := range c.providers.Iterator() {
+		cont.providers[typ] = p
+	}
+
+	return context.WithValue(ctx, containerCtxKey{}, cont)
+}
+
+func (c *Commander) registerProviderCleanup(ctx context.Context) {
+	cont, ok := ctx.Value(containerCtxKey{}).(*container)
+	if !ok || cont.owner != c || !srv.HasCleanup(ctx) || !cont.registered.CompareAndSwap(false, true) {
+		return
+	}
+
+	srv.AddCleanup(ctx, func(context.Context) error { return cont.close() })
+}
+
+func (cont *container) resolve(ctx context.Context, typ reflect.Type) (any, error) {
+	p, ok := cont.providers[typ]
+	if !ok {
+		if cont.parent != nil {
+			return cont.parent.resolve(ctx, typ)
+		}
+		return nil, fmt.Errorf("provider for %s: %w", typ, ErrNotDefined)
+	}
+
+	resolving, _ := ctx.Value(resolvingCtxKey{}).([]reflect.Type)
+	if slices.Contains(resolving, typ) {
+		return nil, fmt.Errorf("resolving %s via %v: %w", typ, resolving, ErrDependencyCycle)
+	}
+
+	cont.mtx.Lock()
+	inst, ok := cont.instances[typ]
+	if !ok {
+		inst = &instance{}
+		cont.instances[typ] = inst
+	}
+	cont.mtx.Unlock()
+
+	inst.once.Do(func() {
+		pctx := context.WithValue(ctx, resolvingCtxKey{}, append(slices.Clip(resolving), typ))
+
+		inst.val, inst.err = p.make(pctx)
+		if inst.err != nil {
+			inst.err = fmt.Errorf("resolving %s: %w", typ, inst.err)
+			return
+		}
+
+		val := inst.val
+		cont.mtx.Lock()
+		defer cont.mtx.Unlock()
+		cont.cleanups = append(cont.cleanups, func() error { return p.cleanup(val) })
+	})
+
+	return inst.val, inst.err
+}
+
+func (cont *container) close() error {
+	cont.mtx.Lock()
+	defer cont.mtx.Unlock()
+
+	var ec erc.Collector
+	for _, fn := range slices.Backward(cont.cleanups) {
+		ec.Push(fn())
+	}
+	cont.cleanups = nil
+
+	return ec.Resolve()
+}
@@ -0,0 +1,115 @@
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+type testClient struct {
+	conf *testConfig
+}
+
+func TestProvider(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("Unregistered", func(t *testing.T) {
+		_, err := Resolve[*testConfig](ctx)
+		assert.ErrorIs(t, err, ErrNotDefined)
+		assert.Panic(t, func() { _ = MustResolve[*testConfig](ctx) })
+	})
+	t.Run("MemoizedWithDependencies", func(t *testing.T) {
+		var order []string
+		constructed := 0
+
+		cmd := MakeRootCommander()
+		Provide(cmd, func(ctx context.Context) (*testConfig, error) {
+			constructed++
+			return &testConfig{Name: "kip"}, nil
+		}, func(*testConfig) error { order = append(order, "config"); return nil })
+		Provide(cmd, func(ctx context.Context) (*testClient, error) {
+			conf, err := Resolve[*testConfig](ctx)
+			if err != nil {
+				return nil, err
+			}
+			return &testClient{conf: conf}, nil
+		}, func(*testClient) error { order = append(order, "client"); return nil })
+
+		sub := MakeCommander().SetName("sub").
+			Hooks(func(ctx context.Context, cc *cli.Command) error {
+				client, err := Resolve[*testClient](ctx)
+				check.NotError(t, err)
+				check.Equal(t, client.conf.Name, "kip")
+				return err
+			}).
+			SetAction(func(ctx context.Context, cc *cli.Command) error {
+				check.Equal(t, MustResolve[*testConfig](ctx), MustResolve[*testClient](ctx).conf)
+				return nil
+			})
+		cmd.Subcommanders(sub)
+
+		assert.NotError(t, Run(ctx, cmd, []string{t.Name(), "sub"}))
+		assert.Equal(t, constructed, 1)
+		assert.EqualItems(t, order, []string{"client", "config"})
+	})
+	t.Run("PerInvocation", func(t *testing.T) {
+		constructed := 0
+		cmd := MakeRootCommander().SetAction(func(ctx context.Context, cc *cli.Command) error {
+			_, err := Resolve[*testConfig](ctx)
+			return err
+		})
+		Provide(cmd, func(ctx context.Context) (*testConfig, error) { constructed++; return &testConfig{}, nil })
+
+		assert.NotError(t, Run(ctx, cmd, []string{t.Name()}))
+		assert.NotError(t, Run(ctx, cmd, []string{t.Name()}))
+		assert.Equal(t, constructed, 2)
+	})
+	t.Run("Errors", func(t *testing.T) {
+		cmd := MakeRootCommander().SetAction(func(ctx context.Context, cc *cli.Command) error {
+			_, err := Resolve[*testClient](ctx)
+			return err
+		})
+		Provide(cmd, func(ctx context.Context) (*testClient, error) { return nil, errors.New("kip") })
+
+		err := Run(ctx, cmd, []string{t.Name()})
+		assert.Error(t, err)
+		assert.Substring(t, err.Error(), "kip")
+	})
+	t.Run("Cycle", func(t *testing.T) {
+		cmd := MakeRootCommander().SetAction(func(ctx context.Context, cc *cli.Command) error {
+			_, err := Resolve[*testClient](ctx)
+			return err
+		})
+		Provide(cmd, func(ctx context.Context) (*testClient, error) {
+			_, err := Resolve[*testConfig](ctx)
+			return &testClient{}, err
+		})
+		Provide(cmd, func(ctx context.Context) (*testConfig, error) {
+			_, err := Resolve[*testClient](ctx)
+			return &testConfig{}, err
+		})
+
+		assert.ErrorIs(t, Run(ctx, cmd, []string{t.Name()}), ErrDependencyCycle)
+	})
+	t.Run("Shadowing", func(t *testing.T) {
+		cmd := MakeRootCommander()
+		Provide(cmd, func(ctx context.Context) (*testConfig, error) { return &testConfig{Name: "root"}, nil })
+		Provide(cmd, func(ctx context.Context) (string, error) { return "root", nil })
+
+		sub := MakeCommander().SetName("sub").SetAction(func(ctx context.Context, cc *cli.Command) error {
+			check.Equal(t, MustResolve[*testConfig](ctx).Name, "sub")
+			check.Equal(t, MustResolve[string](ctx), "root")
+			return nil
+		})
+		Provide(sub, func(ctx context.Context) (*testConfig, error) { return &testConfig{Name: "sub"}, nil })
+		cmd.Subcommanders(sub)
+
+		assert.NotError(t, Run(ctx, cmd, []string{t.Name(), "sub"}))
+	})
+}
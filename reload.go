@@ -0,0 +1,224 @@
+package cmdr
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/srv"
+)
+
+// ReloadOptions configures how the value of an OperationSpec is
+// reloaded while the command runs. Reloads re-run the Constructor and
+// HookOperations of the spec, with the flags of the original
+// invocation, and deliver the new value to the subscribers
+// registered with SubscribeConfig and OnConfigChange. When the
+// Constructor or a HookOperation returns an error, the reload is
+// rejected: the subscribers are not notified, and the running
+// operation keeps the current value.
+//
+// All reloadable specs reload together: with Reload, with the
+// SignalOptions reload signal (SIGHUP), or when the watched file
+// changes.
+type ReloadOptions struct {
+	// FileFlag is the name of the flag whose value is the path of
+	// the configuration file. When the flag is set, the command
+	// polls the file and reloads when the file changes.
+	FileFlag string
+	// Interval is the time between polls of the file. Defaults to
+	// 2 seconds.
+	Interval time.Duration
+	// OnError receives the errors from rejected reloads triggered
+	// by changes to the file. Defaults to logging the error.
+	OnError func(error)
+}
+
+// SetReload makes the value constructed by the spec reloadable.
+func (s *OperationSpec[T]) SetReload(opts ReloadOptions) *OperationSpec[T] {
+	s.Reload = &opts
+	return s
+}
+
+// SubscribeConfig returns a channel that receives the new value of T
+// after each successful reload of a reloadable OperationSpec. The
+// channel holds only the latest value: values that the subscriber has
+// not received are replaced by newer values. The channel is never
+// closed; select on the context to stop receiving.
+func SubscribeConfig[T any](ctx context.Context) (<-chan T, error) {
+	feed, err := getConfigFeed[T](ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan T, 1)
+	feed.mtx.Lock()
+	defer feed.mtx.Unlock()
+	feed.chans = append(feed.chans, ch)
+	return ch, nil
+}
+
+// OnConfigChange registers a callback that receives the new value of
+// T after each successful reload of a reloadable OperationSpec. Errors
+// from the callbacks are returned by Reload.
+func OnConfigChange[T any](ctx context.Context, fn func(context.Context, T) error) error {
+	erc.InvariantOk(fn != nil, "config change callbacks must not be nil")
+	feed, err := getConfigFeed[T](ctx)
+	if err != nil {
+		return err
+	}
+
+	feed.mtx.Lock()
+	defer feed.mtx.Unlock()
+	feed.callbacks = append(feed.callbacks, fn)
+	return nil
+}
+
+func getConfigFeed[T any](ctx context.Context) (*configFeed[T], error) {
+	feed, ok := DefaultContextKey[*configFeed[T]]().Get(ctx)
+	if !ok {
+		var zero T
+		return nil, fmt.Errorf("reloadable configuration for %T: %w", zero, ErrNotDefined)
+	}
+	return feed, nil
+}
+
+// configFeed delivers reloaded values to subscribers: values are
+// staged by the reload hook and published, after all config hooks
+// succeed, by the reload subscriber.
+type configFeed[T any] struct {
+	mtx       sync.Mutex
+	staged    *T
+	chans     []chan T
+	callbacks []func(context.Context, T) error
+}
+
+func (f *configFeed[T]) stage(val T) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.staged = &val
+}
+
+func (f *configFeed[T]) publish(ctx context.Context) error {
+	f.mtx.Lock()
+	staged := f.staged
+	f.staged = nil
+	chans := f.chans
+	callbacks := f.callbacks
+	f.mtx.Unlock()
+
+	if staged == nil {
+		return nil
+	}
+
+	for _, ch := range chans {
+		// replace any value that the subscriber has not
+		// received with the newer value.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- *staged
+	}
+
+	var ec erc.Collector
+	for _, fn := range callbacks {
+		ec.Push(fn(ctx, *staged))
+	}
+	return ec.Resolve()
+}
+
+// registerReload attaches the feed for T to the commander's context
+// and registers the reload of the spec with the reloader.
+func (s *OperationSpec[T]) registerReload(c *Commander, cc *cli.Command) {
+	r, ok := reloaderCtxKey.Get(c.getContext())
+	if !ok {
+		return
+	}
+
+	feed := &configFeed[T]{}
+	c.setContext(DefaultContextKey[*configFeed[T]]().Set(c.getContext(), feed))
+
+	constr := CompositeHook(s.Constructor, s.HookOperations...)
+	r.addHook(func(ctx context.Context) error {
+		val, err := constr(ctx, cc)
+		if err != nil {
+			return err
+		}
+		feed.stage(val)
+		return nil
+	})
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.subscribers = append(r.subscribers, feed.publish)
+}
+
+// watchMiddleware starts a service that reloads the command when the
+// file, named by the FileFlag, changes.
+func (opts ReloadOptions) watchMiddleware() MiddlewareOptions {
+	return MiddlewareOptions{
+		Name: "config watcher",
+		Middleware: func(ctx context.Context, cc *cli.Command) (context.Context, error) {
+			path := cc.String(opts.FileFlag)
+			if path == "" || !srv.HasOrchestrator(ctx) {
+				return ctx, nil
+			}
+
+			interval := secondValueWhenFirstIsZero(opts.Interval, 2*time.Second)
+			onError := opts.OnError
+			if onError == nil {
+				onError = func(err error) { log.Printf("config %q: %v", path, err) }
+			}
+
+			last, err := statFile(path)
+			if err != nil {
+				return ctx, fmt.Errorf("watching config %q: %w", path, err)
+			}
+
+			return ctx, srv.GetOrchestrator(ctx).Add(&srv.Service{
+				Name: "config watcher",
+				Run: func(sctx context.Context) error {
+					ticker := time.NewTicker(interval)
+					defer ticker.Stop()
+
+					for {
+						select {
+						case <-sctx.Done():
+							return nil
+						case <-ticker.C:
+							next, err := statFile(path)
+							if err != nil || next == last {
+								// the file may be missing while
+								// an editor replaces it.
+								continue
+							}
+							last = next
+							if err := Reload(srv.GetBaseContext(ctx)); err != nil {
+								onError(err)
+							}
+						}
+					}
+				},
+			})
+		},
+	}
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func statFile(path string) (fileState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{size: info.Size(), modTime: info.ModTime()}, nil
+}
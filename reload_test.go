@@ -0,0 +1,155 @@
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func readTestConfig(_ context.Context, cc *cli.Command) (*testConfig, error) {
+	data, err := os.ReadFile(cc.String("config"))
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(string(data))
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	return &testConfig{Name: name}, nil
+}
+
+func TestReload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configFlag := MakeFlag(&FlagOptions[string]{Name: "config", TakesFile: true})
+
+	t.Run("NotReloadable", func(t *testing.T) {
+		_, err := SubscribeConfig[*testConfig](ctx)
+		assert.ErrorIs(t, err, ErrNotDefined)
+		assert.ErrorIs(t, OnConfigChange(ctx, func(context.Context, *testConfig) error { return nil }), ErrNotDefined)
+	})
+	t.Run("Reload", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config")
+		assert.NotError(t, os.WriteFile(path, []byte("one"), 0o600))
+
+		var callbacks atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").Flags(configFlag).With(
+			SpecBuilder(readTestConfig).
+				SetReload(ReloadOptions{}).
+				SetAction(func(ctx context.Context, conf *testConfig) error {
+					check.Equal(t, conf.Name, "one")
+
+					updates, err := SubscribeConfig[*testConfig](ctx)
+					assert.NotError(t, err)
+					assert.NotError(t, OnConfigChange(ctx, func(_ context.Context, conf *testConfig) error {
+						callbacks.Add(1)
+						check.Equal(t, conf.Name, "two")
+						return nil
+					}))
+
+					assert.NotError(t, os.WriteFile(path, []byte("two"), 0o600))
+					assert.NotError(t, Reload(ctx))
+					check.Equal(t, (<-updates).Name, "two")
+					check.Equal(t, callbacks.Load(), 1)
+
+					// invalid configurations are rejected
+					assert.NotError(t, os.WriteFile(path, []byte(""), 0o600))
+					err = Reload(ctx)
+					assert.Error(t, err)
+					check.Substring(t, err.Error(), "name is required")
+					check.Equal(t, callbacks.Load(), 1)
+					select {
+					case <-updates:
+						t.Error("rejected reload delivered a value")
+					default:
+					}
+					check.Equal(t, conf.Name, "one")
+					return nil
+				}).Add,
+		)
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "--config", path}))
+	})
+	t.Run("LatestValue", func(t *testing.T) {
+		var value atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").With(
+			SpecBuilder(func(context.Context, *cli.Command) (int64, error) { return value.Add(1), nil }).
+				SetReload(ReloadOptions{}).
+				SetAction(func(ctx context.Context, v int64) error {
+					check.Equal(t, v, 1)
+					updates, err := SubscribeConfig[int64](ctx)
+					assert.NotError(t, err)
+					assert.NotError(t, Reload(ctx))
+					assert.NotError(t, Reload(ctx))
+					check.Equal(t, <-updates, 3)
+					return nil
+				}).Add,
+		)
+		assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+	})
+	t.Run("WatchFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config")
+		assert.NotError(t, os.WriteFile(path, []byte("one"), 0o600))
+
+		rejected := make(chan error, 1)
+		cmd := MakeRootCommander().SetName("tool").Flags(configFlag).With(
+			SpecBuilder(readTestConfig).
+				SetReload(ReloadOptions{
+					FileFlag: "config",
+					Interval: 5 * time.Millisecond,
+					OnError:  func(err error) { rejected <- err },
+				}).
+				SetAction(func(ctx context.Context, conf *testConfig) error {
+					updates, err := SubscribeConfig[*testConfig](ctx)
+					assert.NotError(t, err)
+
+					// ensure that the modification time changes
+					// on file systems with coarse timestamps.
+					later := time.Now().Add(time.Second)
+
+					assert.NotError(t, os.WriteFile(path, []byte(""), 0o600))
+					assert.NotError(t, os.Chtimes(path, later, later))
+					select {
+					case err := <-rejected:
+						check.Substring(t, err.Error(), "name is required")
+					case <-time.After(5 * time.Second):
+						t.Error("invalid reload not rejected")
+					}
+
+					later = later.Add(time.Second)
+					assert.NotError(t, os.WriteFile(path, []byte("two"), 0o600))
+					assert.NotError(t, os.Chtimes(path, later, later))
+					select {
+					case conf := <-updates:
+						check.Equal(t, conf.Name, "two")
+					case <-time.After(5 * time.Second):
+						t.Error("change not reloaded")
+					}
+					return nil
+				}).Add,
+		)
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "--config", path}))
+	})
+	t.Run("WatchMissingFile", func(t *testing.T) {
+		cmd := MakeRootCommander().SetName("tool").Flags(configFlag).With(
+			SpecBuilder(func(context.Context, *cli.Command) (string, error) { return "", nil }).
+				SetReload(ReloadOptions{FileFlag: "config"}).
+				SetAction(func(context.Context, string) error { return nil }).Add,
+		)
+		err := Run(ctx, cmd, []string{"tool", "--config", filepath.Join(t.TempDir(), "missing")})
+		assert.Error(t, err)
+		check.Substring(t, err.Error(), "watching config")
+	})
+}
@@ -0,0 +1,139 @@
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/ers"
+)
+
+// ErrNotRetryable marks errors that the default retry predicate does
+// not retry. Wrap errors with it (e.g. using fmt.Errorf("...: %w",
+// ErrNotRetryable)) to stop retrying.
+const ErrNotRetryable = ers.Error("not retryable")
+
+// RetryOptions retries operations that fail. After each failed
+// attempt, the operation waits for a backoff, which doubles after
+// every attempt up to MaxBackoff, and is jittered by up to half of
+// its duration, before trying again. Retrying stops when an attempt
+// succeeds, when the error is not retryable, when all retries fail,
+// or when the context is canceled. The error from the last attempt
+// aggregates the errors from all of the attempts.
+//
+// Use RetryOptions as an Attachment to retry the action of the
+// commander and of its subcommands, or with OperationSpec.SetRetry
+// to retry the Action of the spec.
+type RetryOptions struct {
+	// Retries is the default value of the retries flag: the
+	// number of retries after the first attempt. Defaults to 3;
+	// use a negative value to not retry unless the flag is set.
+	Retries int
+	// Backoff is the default value of the retry-backoff flag: the
+	// delay before the first retry. Defaults to 100 milliseconds.
+	Backoff time.Duration
+	// MaxBackoff limits the delay between retries. Defaults to
+	// 30 seconds.
+	MaxBackoff time.Duration
+	// Retryable reports whether an attempt that fails with the
+	// error should be retried. By default, all errors are
+	// retryable except context cancellation, errors rooted in
+	// ErrNotRetryable, and recovered panics.
+	Retryable func(error) bool
+
+	// RetriesFlag and BackoffFlag are the names of the
+	// flags. They default to "retries" and "retry-backoff".
+	RetriesFlag string
+	BackoffFlag string
+}
+
+// SetRetry retries the Action of the spec when it fails.
+func (s *OperationSpec[T]) SetRetry(opts RetryOptions) *OperationSpec[T] {
+	s.Retry = &opts
+	return s
+}
+
+// Add attaches the retry flags to the commander, and retries the
+// actions of the commander and its subcommands. Commands without an
+// action, which print their help, are not retried. Use with the
+// Commander.With method.
+func (opts RetryOptions) Add(c *Commander) {
+	c.Flags(opts.flags()...)
+	c.Interceptors(func(next Action) Action {
+		return func(ctx context.Context, cc *cli.Command) error {
+			return opts.do(ctx, cc, func(ctx context.Context) error { return next(ctx, cc) })
+		}
+	})
+}
+
+// Retries retries the actions of the commander and its subcommands,
+// with the default RetryOptions.
+func Retries() Attachment { return RetryOptions{}.Add }
+
+func (opts *RetryOptions) flags() []Flag {
+	opts.Retries = secondValueWhenFirstIsZero(opts.Retries, 3)
+	opts.Backoff = secondValueWhenFirstIsZero(opts.Backoff, 100*time.Millisecond)
+	opts.MaxBackoff = secondValueWhenFirstIsZero(opts.MaxBackoff, 30*time.Second)
+	opts.RetriesFlag = secondValueWhenFirstIsZero(opts.RetriesFlag, "retries")
+	opts.BackoffFlag = secondValueWhenFirstIsZero(opts.BackoffFlag, "retry-backoff")
+
+	return []Flag{
+		MakeFlag(&FlagOptions[int]{
+			Name:    opts.RetriesFlag,
+			Usage:   "number of times to retry the operation when it fails",
+			Default: max(opts.Retries, 0),
+		}),
+		MakeFlag(&FlagOptions[time.Duration]{
+			Name:    opts.BackoffFlag,
+			Usage:   "delay before the first retry, which doubles after each retry",
+			Default: opts.Backoff,
+		}),
+	}
+}
+
+func defaultRetryable(err error) bool {
+	return !ers.IsExpiredContext(err) && !errors.Is(err, ErrNotRetryable) && !errors.Is(err, ers.ErrRecoveredPanic)
+}
+
+// do runs the operation, retrying it according to the flags.
+func (opts *RetryOptions) do(ctx context.Context, cc *cli.Command, op func(context.Context) error) error {
+	retries := cc.Int(opts.RetriesFlag)
+	backoff := max(cc.Duration(opts.BackoffFlag), 0)
+	retryable := opts.Retryable
+	if retryable == nil {
+		retryable = defaultRetryable
+	}
+
+	var ec erc.Collector
+	for attempt := 1; ; attempt++ {
+		err := op(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if attempt == 1 && (retries <= 0 || !retryable(err)) {
+			return err
+		}
+
+		ec.Push(fmt.Errorf("attempt %d: %w", attempt, err))
+		if attempt > retries || !retryable(err) {
+			return fmt.Errorf("after %d attempts: %w", attempt, ec.Resolve())
+		}
+
+		delay := backoff/2 + rand.N(backoff/2+1)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			ec.Push(ctx.Err())
+			return fmt.Errorf("after %d attempts: %w", attempt, ec.Resolve())
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, opts.MaxBackoff)
+	}
+}
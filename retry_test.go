@@ -0,0 +1,184 @@
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	errFlaky := errors.New("flaky")
+
+	flaky := func(attempts *atomic.Int64, failures int64) func(context.Context, *cli.Command) error {
+		return func(context.Context, *cli.Command) error {
+			if n := attempts.Add(1); n <= failures {
+				return fmt.Errorf("failure %d: %w", n, errFlaky)
+			}
+			return nil
+		}
+	}
+
+	t.Run("Succeeds", func(t *testing.T) {
+		var attempts atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").
+			With(RetryOptions{Backoff: time.Millisecond}.Add).
+			SetAction(flaky(&attempts, 2))
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+		check.Equal(t, attempts.Load(), 3)
+	})
+	t.Run("Exhausted", func(t *testing.T) {
+		var attempts atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").
+			With(Retries()).
+			SetAction(flaky(&attempts, 10))
+
+		err := Run(ctx, cmd, []string{"tool", "--retries", "2", "--retry-backoff", "1ms"})
+		assert.ErrorIs(t, err, errFlaky)
+		check.Equal(t, attempts.Load(), 3)
+		check.Substring(t, err.Error(), "after 3 attempts")
+		for n := 1; n <= 3; n++ {
+			check.Substring(t, err.Error(), fmt.Sprintf("attempt %d: failure %d", n, n))
+		}
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		var attempts atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").
+			With(Retries()).
+			SetAction(flaky(&attempts, 10))
+
+		err := Run(ctx, cmd, []string{"tool", "--retries", "0"})
+		assert.ErrorIs(t, err, errFlaky)
+		check.Equal(t, err.Error(), "failure 1: flaky")
+		check.Equal(t, attempts.Load(), 1)
+	})
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		build := func(attempts *atomic.Int64) *Commander {
+			return MakeRootCommander().SetName("tool").
+				With(RetryOptions{Retries: -1, Backoff: time.Millisecond}.Add).
+				SetAction(flaky(attempts, 10))
+		}
+
+		var attempts atomic.Int64
+		assert.ErrorIs(t, Run(ctx, build(&attempts), []string{"tool"}), errFlaky)
+		check.Equal(t, attempts.Load(), 1)
+
+		attempts.Store(0)
+		assert.ErrorIs(t, Run(ctx, build(&attempts), []string{"tool", "--retries", "2"}), errFlaky)
+		check.Equal(t, attempts.Load(), 3)
+	})
+	t.Run("Group", func(t *testing.T) {
+		out := &strings.Builder{}
+		cmd := MakeRootCommander().SetName("tool").
+			SetWriter(out).
+			With(RetryOptions{Backoff: time.Millisecond}.Add).
+			Subcommanders(MakeCommander().SetName("group").Subcommanders(
+				MakeCommander().SetName("leaf").SetAction(func(context.Context, *cli.Command) error { return nil }),
+			))
+
+		err := Run(ctx, cmd, []string{"tool", "group"})
+		assert.ErrorIs(t, err, ErrNotSpecified)
+		check.NotSubstring(t, err.Error(), "attempt")
+		check.Equal(t, strings.Count(out.String(), "COMMANDS:"), 1)
+	})
+	t.Run("NotRetryable", func(t *testing.T) {
+		var attempts atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").
+			With(RetryOptions{Backoff: time.Millisecond}.Add).
+			SetAction(func(context.Context, *cli.Command) error {
+				if attempts.Add(1) == 1 {
+					return errFlaky
+				}
+				return fmt.Errorf("bad input: %w", ErrNotRetryable)
+			})
+
+		err := Run(ctx, cmd, []string{"tool"})
+		assert.ErrorIs(t, err, ErrNotRetryable)
+		assert.ErrorIs(t, err, errFlaky)
+		check.Equal(t, attempts.Load(), 2)
+	})
+	t.Run("Predicate", func(t *testing.T) {
+		var attempts atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").
+			With(RetryOptions{
+				Backoff:   time.Millisecond,
+				Retryable: func(err error) bool { return !errors.Is(err, errFlaky) },
+			}.Add).
+			SetAction(flaky(&attempts, 10))
+
+		assert.ErrorIs(t, Run(ctx, cmd, []string{"tool"}), errFlaky)
+		check.Equal(t, attempts.Load(), 1)
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var attempts atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").
+			With(RetryOptions{Backoff: time.Hour}.Add).
+			SetAction(func(context.Context, *cli.Command) error {
+				attempts.Add(1)
+				time.AfterFunc(time.Millisecond, cancel)
+				return errFlaky
+			})
+
+		start := time.Now()
+		err := Run(ctx, cmd, []string{"tool"})
+		assert.ErrorIs(t, err, errFlaky)
+		assert.ErrorIs(t, err, context.Canceled)
+		check.Equal(t, attempts.Load(), 1)
+		check.True(t, time.Since(start) < time.Minute)
+	})
+	t.Run("Operation", func(t *testing.T) {
+		var attempts atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").With(
+			SpecBuilder(func(context.Context, *cli.Command) (string, error) { return "value", nil }).
+				SetRetry(RetryOptions{Backoff: time.Millisecond}).
+				SetAction(func(_ context.Context, val string) error {
+					check.Equal(t, val, "value")
+					if attempts.Add(1) < 3 {
+						return errFlaky
+					}
+					return nil
+				}).Add,
+		)
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+		check.Equal(t, attempts.Load(), 3)
+	})
+	t.Run("ScheduledOperation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var attempts atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").With(
+			SpecBuilder(func(context.Context, *cli.Command) (int, error) { return 0, nil }).
+				SetRetry(RetryOptions{Backoff: time.Millisecond}).
+				SetSchedule(ScheduleOptions{
+					Interval:       time.Hour,
+					RunImmediately: true,
+					OnError:        func(err error) { t.Error(err) },
+				}).
+				SetAction(func(context.Context, int) error {
+					if attempts.Add(1) < 3 {
+						return errFlaky
+					}
+					cancel()
+					return nil
+				}).Add,
+		)
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+		check.Equal(t, attempts.Load(), 3)
+	})
+}
@@ -0,0 +1,300 @@
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/srv"
+)
+
+// OverlapPolicy determines what happens when a scheduled run is due
+// while the previous run is still running.
+type OverlapPolicy int
+
+const (
+	// OverlapSkip skips runs that are due while the previous run
+	// is running.
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue runs the job again as soon as the previous run
+	// returns. At most one run is queued.
+	OverlapQueue
+	// OverlapCancel cancels the context of the previous run, and
+	// starts the next run once the previous run returns.
+	OverlapCancel
+)
+
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapSkip:
+		return "skip"
+	case OverlapQueue:
+		return "queue"
+	case OverlapCancel:
+		return "cancel"
+	default:
+		return fmt.Sprintf("OverlapPolicy(%d)", int(p))
+	}
+}
+
+// ScheduleOptions turns an action into a scheduled job: rather than
+// running once, the action runs on a schedule, in a service managed
+// by the srv.Orchestrator, until the command's context is canceled.
+// The schedule is either an interval or a cron expression (see the
+// flags,) and exactly one must be set.
+//
+// Cron expressions use the standard five fields (minute, hour, day
+// of month, month, and day of week) in local time, and the
+// descriptors @hourly, @daily, @weekly, @monthly, and @yearly.
+//
+// Errors from runs of the job do not stop the schedule, and are
+// passed to OnError.
+//
+// Use ScheduleOptions as an Attachment to schedule the action of
+// the commander and of its subcommands, or with
+// OperationSpec.SetSchedule to schedule the Action of the spec.
+type ScheduleOptions struct {
+	// Interval is the default value of the interval flag.
+	Interval time.Duration
+	// Cron is the default value of the cron flag.
+	Cron string
+	// Jitter is the default value of the jitter flag: each run
+	// is delayed by a random duration up to the jitter.
+	Jitter time.Duration
+	// RunImmediately is the default value of the
+	// run-immediately flag: when set, the job runs once when the
+	// command starts, and then on the schedule.
+	RunImmediately bool
+	// Overlap is the policy for runs that are due while the
+	// previous run is running. Defaults to OverlapSkip.
+	Overlap OverlapPolicy
+	// OnError receives the errors from runs of the job. Defaults
+	// to logging the error.
+	OnError func(error)
+
+	// IntervalFlag, CronFlag, JitterFlag, and RunImmediatelyFlag
+	// are the names of the flags. They default to "interval",
+	// "cron", "jitter", and "run-immediately".
+	IntervalFlag       string
+	CronFlag           string
+	JitterFlag         string
+	RunImmediatelyFlag string
+}
+
+// SetSchedule makes the action of the spec a scheduled job.
+func (s *OperationSpec[T]) SetSchedule(opts ScheduleOptions) *OperationSpec[T] {
+	s.Schedule = &opts
+	return s
+}
+
+// Add attaches the schedule flags to the commander, and runs the
+// actions of the commander and its subcommands on the
+// schedule. Commands without an action, which print their help, are
+// not scheduled. Use with the Commander.With method.
+func (opts ScheduleOptions) Add(c *Commander) {
+	c.Flags(opts.flags()...)
+	c.Interceptors(func(next Action) Action {
+		return func(ctx context.Context, cc *cli.Command) error {
+			return opts.run(ctx, cc, func(ctx context.Context) error { return next(ctx, cc) })
+		}
+	})
+}
+
+// Scheduled runs the actions of the commander and its subcommands on
+// the schedule set by the flags, with the default ScheduleOptions.
+func Scheduled() Attachment { return ScheduleOptions{}.Add }
+
+func (opts *ScheduleOptions) flags() []Flag {
+	opts.IntervalFlag = secondValueWhenFirstIsZero(opts.IntervalFlag, "interval")
+	opts.CronFlag = secondValueWhenFirstIsZero(opts.CronFlag, "cron")
+	opts.JitterFlag = secondValueWhenFirstIsZero(opts.JitterFlag, "jitter")
+	opts.RunImmediatelyFlag = secondValueWhenFirstIsZero(opts.RunImmediatelyFlag, "run-immediately")
+
+	return []Flag{
+		MakeFlag(&FlagOptions[time.Duration]{
+			Name:    opts.IntervalFlag,
+			Usage:   "run the job at this interval",
+			Default: opts.Interval,
+		}),
+		MakeFlag(&FlagOptions[string]{
+			Name:    opts.CronFlag,
+			Usage:   "run the job on this cron schedule (e.g. '*/15 * * * *' or '@daily')",
+			Default: opts.Cron,
+		}),
+		MakeFlag(&FlagOptions[time.Duration]{
+			Name:    opts.JitterFlag,
+			Usage:   "delay each run by a random duration up to this value",
+			Default: opts.Jitter,
+		}),
+		MakeFlag(&FlagOptions[bool]{
+			Name:    opts.RunImmediatelyFlag,
+			Usage:   "run the job when the command starts, and then on the schedule",
+			Default: opts.RunImmediately,
+		}),
+	}
+}
+
+// next resolves the schedule from the flags.
+func (opts *ScheduleOptions) next(cc *cli.Command) (func(time.Time) time.Time, error) {
+	interval := cc.Duration(opts.IntervalFlag)
+	expr := cc.String(opts.CronFlag)
+
+	switch {
+	case interval != 0 && expr != "":
+		return nil, fmt.Errorf("--%s and --%s are mutually exclusive: %w", opts.IntervalFlag, opts.CronFlag, ErrInvalidSchedule)
+	case interval < 0:
+		return nil, fmt.Errorf("--%s %s must be positive: %w", opts.IntervalFlag, interval, ErrInvalidSchedule)
+	case interval > 0:
+		return func(t time.Time) time.Time { return t.Add(interval) }, nil
+	case expr != "":
+		sched, err := parseCron(expr)
+		if err != nil {
+			return nil, err
+		}
+		return sched.next, nil
+	default:
+		return nil, fmt.Errorf("one of --%s or --%s: %w", opts.IntervalFlag, opts.CronFlag, ErrNotSpecified)
+	}
+}
+
+// run starts the scheduler service and blocks until the context is
+// canceled.
+func (opts *ScheduleOptions) run(ctx context.Context, cc *cli.Command, job func(context.Context) error) error {
+	next, err := opts.next(cc)
+	if err != nil {
+		return err
+	}
+	if !srv.HasOrchestrator(ctx) {
+		return fmt.Errorf("orchestrator for scheduled job %q: %w", cc.FullName(), ErrNotDefined)
+	}
+
+	onError := opts.OnError
+	if onError == nil {
+		onError = func(err error) { log.Printf("scheduled job %q: %v", cc.FullName(), err) }
+	}
+
+	sched := &scheduler{
+		job:     job,
+		overlap: opts.Overlap,
+		onError: onError,
+	}
+
+	jitter := cc.Duration(opts.JitterFlag)
+	immediate := cc.Bool(opts.RunImmediatelyFlag)
+	done := make(chan struct{})
+
+	err = srv.GetOrchestrator(ctx).Add(&srv.Service{
+		Name: "scheduler " + cc.FullName(),
+		Run: func(sctx context.Context) error {
+			defer close(done)
+			defer sched.wg.Wait()
+
+			if immediate {
+				sched.trigger(sctx)
+			}
+
+			for at := next(time.Now()); !at.IsZero(); at = next(at) {
+				delay := time.Until(at)
+				if jitter > 0 {
+					delay += rand.N(jitter)
+				}
+
+				timer := time.NewTimer(delay)
+				select {
+				case <-sctx.Done():
+					timer.Stop()
+					return nil
+				case <-timer.C:
+					sched.trigger(sctx)
+				}
+
+				// skip runs that are past due, for instance
+				// after the system sleeps.
+				if now := time.Now(); at.Before(now) {
+					at = now
+				}
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-done:
+	}
+	return nil
+}
+
+// scheduler runs the job, applying the overlap policy.
+type scheduler struct {
+	job     func(context.Context) error
+	overlap OverlapPolicy
+	onError func(error)
+
+	wg     sync.WaitGroup
+	mtx    sync.Mutex
+	cancel context.CancelFunc
+	queued bool
+}
+
+func (s *scheduler) trigger(ctx context.Context) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.cancel == nil {
+		s.start(ctx)
+		return
+	}
+
+	switch s.overlap {
+	case OverlapQueue:
+		s.queued = true
+	case OverlapCancel:
+		s.cancel()
+		s.queued = true
+	}
+}
+
+// start runs the job. The caller must hold the mutex.
+func (s *scheduler) start(ctx context.Context) {
+	jctx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+		err := runJob(jctx, s.job)
+		canceled := jctx.Err() != nil && errors.Is(err, context.Canceled)
+		cancel()
+		if err != nil && !canceled {
+			s.onError(err)
+		}
+
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		s.cancel = nil
+		if s.queued && ctx.Err() == nil {
+			s.queued = false
+			s.start(ctx)
+		}
+	}()
+}
+
+func runJob(ctx context.Context, job func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = erc.ParsePanic(r)
+		}
+	}()
+	return job(ctx)
+}
@@ -0,0 +1,192 @@
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+	"github.com/tychoish/fun/ers"
+)
+
+func TestSchedule(t *testing.T) {
+	t.Run("OverlapPolicyString", func(t *testing.T) {
+		check.Equal(t, OverlapSkip.String(), "skip")
+		check.Equal(t, OverlapQueue.String(), "queue")
+		check.Equal(t, OverlapCancel.String(), "cancel")
+		check.Equal(t, OverlapPolicy(42).String(), "OverlapPolicy(42)")
+	})
+	t.Run("Flags", func(t *testing.T) {
+		build := func() *Commander {
+			return MakeRootCommander().SetName("tool").With(
+				SpecBuilder(func(context.Context, *cli.Command) (int, error) { return 0, nil }).
+					SetSchedule(ScheduleOptions{}).
+					SetAction(func(context.Context, int) error { return nil }).Add,
+			)
+		}
+		ctx := context.Background()
+
+		assert.ErrorIs(t, Run(ctx, build(), []string{"tool"}), ErrNotSpecified)
+		assert.ErrorIs(t, Run(ctx, build(), []string{"tool", "--interval", "1s", "--cron", "@daily"}), ErrInvalidSchedule)
+		assert.ErrorIs(t, Run(ctx, build(), []string{"tool", "--interval", "-1s"}), ErrInvalidSchedule)
+		assert.ErrorIs(t, Run(ctx, build(), []string{"tool", "--cron", "* * *"}), ErrInvalidSchedule)
+	})
+	t.Run("Interval", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var runs atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").With(
+			SpecBuilder(func(context.Context, *cli.Command) (string, error) { return "value", nil }).
+				SetSchedule(ScheduleOptions{Interval: 5 * time.Millisecond}).
+				SetAction(func(_ context.Context, val string) error {
+					check.Equal(t, val, "value")
+					if runs.Add(1) == 3 {
+						cancel()
+					}
+					return nil
+				}).Add,
+		)
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "--jitter", "1ms"}))
+		check.True(t, runs.Load() >= 3)
+	})
+	t.Run("Attachment", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var runs atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").
+			With(Scheduled()).
+			SetAction(func(context.Context, *cli.Command) error {
+				if runs.Add(1) == 3 {
+					cancel()
+				}
+				return nil
+			})
+
+		assert.ErrorIs(t, Run(ctx, cmd, []string{"tool"}), ErrNotSpecified)
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "--interval", "5ms"}))
+		check.True(t, runs.Load() >= 3)
+	})
+	t.Run("Group", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		out := &strings.Builder{}
+		cmd := MakeRootCommander().SetName("tool").
+			SetWriter(out).
+			With(Scheduled()).
+			Subcommanders(MakeCommander().SetName("group").Subcommanders(
+				MakeCommander().SetName("leaf").SetAction(func(context.Context, *cli.Command) error { return nil }),
+			))
+
+		assert.ErrorIs(t, Run(ctx, cmd, []string{"tool", "--interval", "1ms", "group"}), ErrNotSpecified)
+		check.NotError(t, ctx.Err())
+		check.Equal(t, strings.Count(out.String(), "COMMANDS:"), 1)
+	})
+	t.Run("RunImmediately", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var runs atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").With(
+			SpecBuilder(func(context.Context, *cli.Command) (int, error) { return 0, nil }).
+				SetSchedule(ScheduleOptions{Cron: "@yearly"}).
+				SetAction(func(context.Context, int) error { runs.Add(1); cancel(); return nil }).Add,
+		)
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "--run-immediately"}))
+		check.Equal(t, runs.Load(), 1)
+	})
+	t.Run("Errors", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		errs := make(chan error, 2)
+		var runs atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").With(
+			SpecBuilder(func(context.Context, *cli.Command) (int, error) { return 0, nil }).
+				SetSchedule(ScheduleOptions{
+					Interval: time.Millisecond,
+					OnError: func(err error) {
+						errs <- err
+						if len(errs) == cap(errs) {
+							cancel()
+						}
+					},
+				}).
+				SetAction(func(context.Context, int) error {
+					if runs.Add(1) == 1 {
+						return errors.New("failed")
+					}
+					panic("boom")
+				}).Add,
+		)
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+		check.Equal(t, (<-errs).Error(), "failed")
+		assert.ErrorIs(t, <-errs, ers.ErrRecoveredPanic)
+	})
+	t.Run("Overlap", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		build := func(policy OverlapPolicy, job func(context.Context) error) (*scheduler, *atomic.Int64, *atomic.Int64) {
+			var runs, failures atomic.Int64
+			return &scheduler{
+				overlap: policy,
+				job:     func(ctx context.Context) error { runs.Add(1); return job(ctx) },
+				onError: func(error) { failures.Add(1) },
+			}, &runs, &failures
+		}
+
+		t.Run("Skip", func(t *testing.T) {
+			release := make(chan struct{})
+			sched, runs, _ := build(OverlapSkip, func(context.Context) error { <-release; return nil })
+			sched.trigger(ctx)
+			sched.trigger(ctx)
+			sched.trigger(ctx)
+			close(release)
+			sched.wg.Wait()
+			check.Equal(t, runs.Load(), 1)
+		})
+		t.Run("Queue", func(t *testing.T) {
+			release := make(chan struct{})
+			sched, runs, _ := build(OverlapQueue, func(context.Context) error { <-release; return nil })
+			sched.trigger(ctx)
+			waitFor(t, func() bool { return runs.Load() == 1 })
+			sched.trigger(ctx)
+			sched.trigger(ctx)
+			close(release)
+			waitFor(t, func() bool { return runs.Load() == 2 })
+			sched.wg.Wait()
+			check.Equal(t, runs.Load(), 2)
+		})
+		t.Run("Cancel", func(t *testing.T) {
+			var canceled atomic.Int64
+			sched, runs, failures := build(OverlapCancel, func(ctx context.Context) error {
+				select {
+				case <-ctx.Done():
+					canceled.Add(1)
+					return ctx.Err()
+				case <-time.After(50 * time.Millisecond):
+					return nil
+				}
+			})
+			sched.trigger(ctx)
+			waitFor(t, func() bool { return runs.Load() == 1 })
+			sched.trigger(ctx)
+			waitFor(t, func() bool { return runs.Load() == 2 })
+			sched.wg.Wait()
+			check.Equal(t, canceled.Load(), 1)
+			check.Equal(t, failures.Load(), 0)
+		})
+	})
+}
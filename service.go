@@ -0,0 +1,210 @@
+package cmdr
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/ers"
+	"github.com/tychoish/fun/srv"
+)
+
+// ErrShutdownTimeout is at the root of the errors reported when a
+// service does not return within its shutdown timeout.
+const ErrShutdownTimeout = ers.Error("service did not shut down")
+
+// RestartPolicy determines when a service restarts after its Run
+// function returns.
+type RestartPolicy int
+
+const (
+	// RestartNever services run once.
+	RestartNever RestartPolicy = iota
+	// RestartOnFailure services restart when they return an
+	// error or panic.
+	RestartOnFailure
+	// RestartAlways services restart whenever they return, until
+	// the command shuts down.
+	RestartAlways
+)
+
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartNever:
+		return "never"
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	default:
+		return fmt.Sprintf("RestartPolicy(%d)", int(p))
+	}
+}
+
+// ServiceOptions declares a service that runs, managed by the
+// srv.Orchestrator, while a command runs. Services are scoped to the
+// commander that declares them: the services of a subcommand only
+// start when that subcommand (or one of its subcommands) runs. The
+// services start during the middleware phase, and the command waits
+// for them to return before Run returns.
+//
+// When a service fails, and its restart policy does not restart it
+// (or it has restarted MaxRestarts times), the error, naming the
+// service, is returned by Run. When the service is Critical, the
+// failure also cancels the context of the command, so that the
+// action and all of the other services shut down.
+//
+// Restarts are recorded in the cmdr.service.restarts metric
+// when the command has metrics (see MetricsOptions).
+type ServiceOptions struct {
+	// Name identifies the service in errors and metrics, and is
+	// required.
+	Name string
+	// Run is the service, and is required. Run must return when
+	// its context is canceled.
+	Run func(context.Context) error
+	// Restart is the restart policy. Defaults to RestartNever.
+	Restart RestartPolicy
+	// MaxRestarts limits the number of restarts. When zero, the
+	// number of restarts is unlimited.
+	MaxRestarts int
+	// Backoff is the delay before the first restart, which
+	// doubles after each restart, up to MaxBackoff. Defaults to
+	// 100 milliseconds.
+	Backoff time.Duration
+	// MaxBackoff limits the delay between restarts. Defaults to
+	// 30 seconds.
+	MaxBackoff time.Duration
+	// ShutdownTimeout is how long the command waits for the
+	// service to return after the command begins to shut down.
+	// When zero, the command waits until the service returns.
+	ShutdownTimeout time.Duration
+	// Critical services shut down the command when they fail.
+	Critical bool
+}
+
+// Services declares services that run while the command runs. See
+// ServiceOptions.
+func (c *Commander) Services(services ...ServiceOptions) *Commander {
+	for _, opts := range services {
+		opts.Add(c)
+	}
+	return c
+}
+
+// Add declares the service on the commander. Use with the
+// Commander.With method.
+func (opts ServiceOptions) Add(c *Commander) {
+	erc.InvariantOk(opts.Name != "", "services must have names")
+	erc.InvariantOk(opts.Run != nil, "service", opts.Name, "must have a run function")
+	opts.Backoff = secondValueWhenFirstIsZero(opts.Backoff, 100*time.Millisecond)
+	opts.MaxBackoff = secondValueWhenFirstIsZero(opts.MaxBackoff, 30*time.Second)
+
+	c.AddMiddleware(MiddlewareOptions{
+		Name: "service " + opts.Name,
+		Middleware: func(ctx context.Context, _ *cli.Command) (context.Context, error) {
+			if !srv.HasOrchestrator(ctx) {
+				return ctx, fmt.Errorf("orchestrator for service %q: %w", opts.Name, ErrNotDefined)
+			}
+
+			return ctx, srv.GetOrchestrator(ctx).Add(&srv.Service{
+				Name: opts.Name,
+				Run: func(sctx context.Context) error {
+					err := opts.supervise(sctx)
+					if err != nil && opts.Critical && srv.HasShutdownSignal(ctx) {
+						srv.GetShutdownSignal(ctx)()
+					}
+					return err
+				},
+			})
+		},
+	})
+}
+
+// supervise runs the service, restarting it according to its
+// policy, until the service stops or the context is canceled.
+func (opts ServiceOptions) supervise(ctx context.Context) error {
+	backoff := opts.Backoff
+	for restarts := 0; ; restarts++ {
+		err := opts.runOnce(ctx)
+
+		if ctx.Err() != nil {
+			if err == nil || ers.IsExpiredContext(err) {
+				return nil
+			}
+			return opts.failed(err)
+		}
+
+		switch {
+		case opts.Restart == RestartNever:
+		case opts.Restart == RestartOnFailure && err == nil:
+		case opts.MaxRestarts > 0 && restarts >= opts.MaxRestarts:
+			if err != nil {
+				return opts.failed(fmt.Errorf("after %d restarts: %w", restarts, err))
+			}
+			return nil
+		default:
+			if m, ok := MetricsFromContext(ctx); ok {
+				m.ServiceRestarted(ctx, opts.Name)
+			}
+
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-timer.C:
+			}
+			backoff = min(2*backoff, opts.MaxBackoff)
+			continue
+		}
+
+		if err != nil {
+			return opts.failed(err)
+		}
+		return nil
+	}
+}
+
+func (opts ServiceOptions) failed(err error) error {
+	if opts.Critical {
+		return fmt.Errorf("critical service %q failed: %w", opts.Name, err)
+	}
+	return fmt.Errorf("service %q failed: %w", opts.Name, err)
+}
+
+// runOnce runs the service, converting panics into errors, and
+// enforcing the shutdown timeout once the context is canceled.
+func (opts ServiceOptions) runOnce(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- erc.ParsePanic(r)
+			}
+		}()
+		done <- opts.Run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	if opts.ShutdownTimeout <= 0 {
+		return <-done
+	}
+
+	timer := time.NewTimer(opts.ShutdownTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("within %s: %w", opts.ShutdownTimeout, ErrShutdownTimeout)
+	}
+}
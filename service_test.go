@@ -0,0 +1,181 @@
+package cmdr
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+	"github.com/tychoish/fun/ers"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+	}
+}
+
+func TestServices(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("RestartPolicyString", func(t *testing.T) {
+		check.Equal(t, RestartNever.String(), "never")
+		check.Equal(t, RestartOnFailure.String(), "on-failure")
+		check.Equal(t, RestartAlways.String(), "always")
+		check.Equal(t, RestartPolicy(42).String(), "RestartPolicy(42)")
+	})
+	t.Run("Validation", func(t *testing.T) {
+		assert.Panic(t, func() { MakeCommander().Services(ServiceOptions{Run: func(context.Context) error { return nil }}) })
+		assert.Panic(t, func() { MakeCommander().Services(ServiceOptions{Name: "svc"}) })
+	})
+	t.Run("RestartOnFailure", func(t *testing.T) {
+		var attempts atomic.Int64
+		reader := sdkmetric.NewManualReader()
+		cmd := MakeRootCommander().SetName("tool").
+			With(MetricsOptions{MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))}.Add).
+			Services(ServiceOptions{
+				Name:    "flaky",
+				Restart: RestartOnFailure,
+				Backoff: time.Millisecond,
+				Run: func(ctx context.Context) error {
+					if attempts.Add(1) < 3 {
+						return errors.New("transient")
+					}
+					<-ctx.Done()
+					return ctx.Err()
+				},
+			}).
+			SetAction(func(context.Context, *cli.Command) error {
+				waitFor(t, func() bool { return attempts.Load() == 3 })
+				return nil
+			})
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+		check.Equal(t, metricValue(t, reader, "cmdr.service.restarts", attribute.String("service", "flaky")), 2)
+	})
+	t.Run("MaxRestarts", func(t *testing.T) {
+		var attempts atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").
+			Services(ServiceOptions{
+				Name:        "flaky",
+				Restart:     RestartOnFailure,
+				MaxRestarts: 2,
+				Backoff:     time.Millisecond,
+				// critical, so that the command runs until
+				// the service stops restarting.
+				Critical: true,
+				Run:      func(context.Context) error { attempts.Add(1); return errors.New("broken") },
+			}).
+			SetAction(func(ctx context.Context, _ *cli.Command) error {
+				<-ctx.Done()
+				return nil
+			})
+
+		err := Run(ctx, cmd, []string{"tool"})
+		assert.Error(t, err)
+		check.Substring(t, err.Error(), `service "flaky" failed: after 2 restarts: broken`)
+		check.Equal(t, attempts.Load(), 3)
+	})
+	t.Run("RestartAlways", func(t *testing.T) {
+		var attempts atomic.Int64
+		cmd := MakeRootCommander().SetName("tool").
+			Services(ServiceOptions{
+				Name:    "loop",
+				Restart: RestartAlways,
+				Backoff: time.Millisecond,
+				Run:     func(context.Context) error { attempts.Add(1); return nil },
+			}).
+			SetAction(func(context.Context, *cli.Command) error {
+				waitFor(t, func() bool { return attempts.Load() >= 3 })
+				return nil
+			})
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+	})
+	t.Run("Panic", func(t *testing.T) {
+		cmd := MakeRootCommander().SetName("tool").
+			Services(ServiceOptions{
+				Name: "panics",
+				Run:  func(context.Context) error { panic("boom") },
+			}).
+			SetAction(func(context.Context, *cli.Command) error { return nil })
+
+		err := Run(ctx, cmd, []string{"tool"})
+		assert.ErrorIs(t, err, ers.ErrRecoveredPanic)
+		check.Substring(t, err.Error(), `service "panics" failed`)
+	})
+	t.Run("Critical", func(t *testing.T) {
+		cmd := MakeRootCommander().SetName("tool").
+			Services(
+				ServiceOptions{
+					Name:     "db",
+					Critical: true,
+					Run:      func(context.Context) error { return errors.New("connection lost") },
+				},
+				ServiceOptions{
+					Name: "worker",
+					Run:  func(ctx context.Context) error { <-ctx.Done(); return nil },
+				},
+			).
+			SetAction(func(ctx context.Context, _ *cli.Command) error {
+				select {
+				case <-ctx.Done():
+				case <-time.After(5 * time.Second):
+					t.Error("critical failure did not cancel the context")
+				}
+				return nil
+			})
+
+		err := Run(ctx, cmd, []string{"tool"})
+		assert.Error(t, err)
+		check.Substring(t, err.Error(), `critical service "db" failed: connection lost`)
+	})
+	t.Run("ShutdownTimeout", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		cmd := MakeRootCommander().SetName("tool").
+			Services(ServiceOptions{
+				Name:            "stuck",
+				ShutdownTimeout: 10 * time.Millisecond,
+				Run:             func(context.Context) error { <-release; return nil },
+			}).
+			SetAction(func(context.Context, *cli.Command) error { return nil })
+
+		err := Run(ctx, cmd, []string{"tool"})
+		assert.ErrorIs(t, err, ErrShutdownTimeout)
+		check.Substring(t, err.Error(), `service "stuck" failed`)
+	})
+	t.Run("Scoped", func(t *testing.T) {
+		var started atomic.Bool
+		cmd := MakeRootCommander().SetName("tool").Subcommanders(
+			MakeCommander().SetName("serve").
+				Services(ServiceOptions{
+					Name: "server",
+					Run:  func(ctx context.Context) error { started.Store(true); <-ctx.Done(); return nil },
+				}).
+				SetAction(func(context.Context, *cli.Command) error {
+					waitFor(t, started.Load)
+					return nil
+				}),
+			MakeCommander().SetName("check").
+				SetAction(func(context.Context, *cli.Command) error { return nil }),
+		)
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "check"}))
+		check.True(t, !started.Load())
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "serve"}))
+		check.True(t, started.Load())
+	})
+}
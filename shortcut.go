@@ -0,0 +1,234 @@
+package cmdr
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/ers"
+)
+
+// ErrShortcutCycle is returned by Run when the expansion of a
+// shortcut refers back to itself.
+const ErrShortcutCycle = ers.Error("recursive shortcut")
+
+const shortcutCategory = "shortcuts"
+
+// Shortcut defines a user-defined command that expands to a full
+// sequence of arguments, as in:
+//
+//	cmd.Shortcut("deploy-prod", "deploy", "--env=prod", "--confirm")
+//
+// Shortcuts are expanded by Run (and Main) before the arguments are
+// parsed, and only for the commander passed to Run: when the first
+// positional argument names a shortcut, it's replaced by the
+// expansion and the remaining arguments follow the expansion. The
+// expansion may itself begin with a shortcut. Subcommands (and
+// their aliases) always take precedence over shortcuts with the same
+// name. Shortcuts are listed in help output.
+func (c *Commander) Shortcut(name string, expansion ...string) *Commander {
+	c.shortcuts.Store(name, expansion)
+	return c
+}
+
+// ShortcutsFile loads shortcuts from a file when the commander is
+// resolved, so that shortcuts may be defined in user configuration.
+// Each line of the file defines a shortcut, as in:
+//
+//	# comments and blank lines are ignored
+//	deploy-prod = deploy --env=prod --confirm
+//	greet = say "hello world"
+//
+// Missing files are ignored; other errors reading or parsing the
+// file are reported when the command runs. Shortcuts defined in the
+// file override shortcuts with the same name defined in code.
+func ShortcutsFile(path string) Attachment {
+	return func(c *Commander) {
+		c.onResolve(func(c *Commander) {
+			shortcuts, err := ReadShortcuts(path)
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+			case err != nil:
+				c.Hooks(func(context.Context, *cli.Command) error { return err })
+			default:
+				for name, expansion := range shortcuts {
+					c.Shortcut(name, expansion...)
+				}
+			}
+		})
+	}
+}
+
+// ReadShortcuts parses a shortcuts file, in the format described by
+// ShortcutsFile.
+func ReadShortcuts(path string) (map[string][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	out := map[string][]string{}
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("%s:%d: invalid shortcut definition %q", path, lineNum, line)
+		}
+
+		expansion, err := splitArgs(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		if len(expansion) == 0 {
+			return nil, fmt.Errorf("%s:%d: shortcut %q has no expansion", path, lineNum, name)
+		}
+
+		out[name] = expansion
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// splitArgs splits a string into arguments on whitespace, respecting
+// single and double quotes.
+func splitArgs(in string) ([]string, error) {
+	var (
+		out     []string
+		current strings.Builder
+		quote   rune
+		inArg   bool
+	)
+
+	for _, r := range in {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				out = append(out, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", strings.TrimSpace(in))
+	}
+	if inArg {
+		out = append(out, current.String())
+	}
+
+	return out, nil
+}
+
+// addShortcutCommands adds placeholder subcommands so that shortcuts
+// appear in help text and completion. The shortcuts themselves are
+// expanded by Run before the arguments are parsed.
+func (c *Commander) addShortcutCommands() {
+	if c.shortcuts.Len() == 0 {
+		return
+	}
+
+	defined := c.subcommandNames()
+	for _, name := range slices.Sorted(c.shortcuts.Keys()) {
+		if defined.Check(name) {
+			continue
+		}
+
+		expansion := c.shortcuts.Get(name)
+		sub := MakeCommander().
+			SetName(name).
+			SetUsage(fmt.Sprintf("shortcut for %q", strings.Join(expansion, " "))).
+			SetAction(func(context.Context, *cli.Command) error {
+				return fmt.Errorf("shortcut %q must be expanded by cmdr.Run: %w", name, ErrNotSpecified)
+			})
+		sub.cmd.SkipFlagParsing = true
+		sub.cmd.Category = shortcutCategory
+
+		c.Subcommanders(sub)
+	}
+}
+
+// expandShortcuts replaces the first positional argument with its
+// expansion, when it names a shortcut.
+func (c *Commander) expandShortcuts(app *cli.Command, args []string) ([]string, error) {
+	if c.shortcuts.Len() == 0 || len(args) < 2 {
+		return args, nil
+	}
+
+	idx := firstPositionalArg(app, args)
+	if idx < 0 {
+		return args, nil
+	}
+
+	var seen []string
+	for {
+		name := args[idx]
+		if sub := app.Command(name); sub != nil && sub.Category != shortcutCategory {
+			return args, nil
+		}
+
+		expansion, ok := c.shortcuts.Load(name)
+		if !ok {
+			return args, nil
+		}
+
+		if slices.Contains(seen, name) {
+			return nil, fmt.Errorf("shortcut %q via %s: %w", name, strings.Join(seen, " -> "), ErrShortcutCycle)
+		}
+		seen = append(seen, name)
+
+		args = slices.Concat(args[:idx], expansion, args[idx+1:])
+	}
+}
+
+// firstPositionalArg returns the index of the first argument that is
+// neither a flag nor the value of a flag, or -1 if there are none.
+func firstPositionalArg(app *cli.Command, args []string) int {
+	for idx := 1; idx < len(args); idx++ {
+		arg := args[idx]
+		switch {
+		case arg == "--":
+			return -1
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			return idx
+		case strings.Contains(arg, "="):
+			continue
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		for _, flag := range app.Flags {
+			if df, ok := flag.(cli.DocGenerationFlag); ok && df.TakesValue() && slices.Contains(flag.Names(), name) {
+				idx++
+				break
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,115 @@
+package cmdr
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func TestShortcuts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	build := func(env *string, confirm *bool) *Commander {
+		deploy := MakeCommander().SetName("deploy").
+			Flags(
+				MakeFlag(&FlagOptions[string]{Name: "env"}),
+				MakeFlag(&FlagOptions[bool]{Name: "confirm"}),
+			).
+			SetAction(func(ctx context.Context, cc *cli.Command) error {
+				*env = cc.String("env")
+				*confirm = cc.Bool("confirm")
+				return nil
+			})
+		return MakeRootCommander().SetName("tool").
+			Flags(MakeFlag(&FlagOptions[string]{Name: "level"})).
+			Subcommanders(deploy)
+	}
+
+	t.Run("Expansion", func(t *testing.T) {
+		var env string
+		var confirm bool
+		cmd := build(&env, &confirm).
+			Shortcut("deploy-prod", "deploy", "--env=prod").
+			Shortcut("ship", "deploy-prod", "--confirm")
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "--level", "info", "ship"}))
+		check.Equal(t, env, "prod")
+		check.True(t, confirm)
+	})
+	t.Run("TrailingArguments", func(t *testing.T) {
+		var env string
+		var confirm bool
+		cmd := build(&env, &confirm).Shortcut("deploy-prod", "deploy", "--env=prod")
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "deploy-prod", "--confirm"}))
+		check.Equal(t, env, "prod")
+		check.True(t, confirm)
+	})
+	t.Run("Recursion", func(t *testing.T) {
+		var env string
+		var confirm bool
+		cmd := build(&env, &confirm).Shortcut("one", "two", "--confirm").Shortcut("two", "one")
+
+		assert.ErrorIs(t, Run(ctx, cmd, []string{"tool", "one"}), ErrShortcutCycle)
+	})
+	t.Run("SubcommandPrecedence", func(t *testing.T) {
+		var env string
+		var confirm bool
+		cmd := build(&env, &confirm).Shortcut("deploy", "deploy", "--env=prod")
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "deploy"}))
+		check.Equal(t, env, "")
+	})
+	t.Run("Help", func(t *testing.T) {
+		var env string
+		var confirm bool
+		cmd := build(&env, &confirm).Shortcut("deploy-prod", "deploy", "--env=prod")
+		cmd.setContext(ctx)
+
+		sub := cmd.Command().Command("deploy-prod")
+		assert.True(t, sub != nil)
+		check.Equal(t, sub.Category, "shortcuts")
+		check.Substring(t, sub.Usage, "deploy --env=prod")
+	})
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "shortcuts")
+		assert.NotError(t, os.WriteFile(path, []byte("# comment\n\ndeploy-prod = deploy --env 'prod env' --confirm\n"), 0o600))
+
+		var env string
+		var confirm bool
+		cmd := build(&env, &confirm).With(ShortcutsFile(path))
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "deploy-prod"}))
+		check.Equal(t, env, "prod env")
+		check.True(t, confirm)
+	})
+	t.Run("FileMissing", func(t *testing.T) {
+		var env string
+		var confirm bool
+		cmd := build(&env, &confirm).With(ShortcutsFile(filepath.Join(t.TempDir(), "none")))
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "deploy"}))
+	})
+	t.Run("FileInvalid", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "shortcuts")
+		assert.NotError(t, os.WriteFile(path, []byte("deploy-prod deploy\n"), 0o600))
+
+		var env string
+		var confirm bool
+		cmd := build(&env, &confirm).With(ShortcutsFile(path))
+		assert.Error(t, Run(ctx, cmd, []string{"tool", "deploy"}))
+	})
+	t.Run("SplitArgs", func(t *testing.T) {
+		out, err := splitArgs(` one "two three" 'four"' `)
+		assert.NotError(t, err)
+		assert.EqualItems(t, out, []string{"one", "two three", `four"`})
+
+		_, err = splitArgs(`"one`)
+		assert.Error(t, err)
+	})
+}
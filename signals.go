@@ -0,0 +1,261 @@
+package cmdr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/dt"
+	"github.com/tychoish/fun/erc"
+	"github.com/tychoish/fun/srv"
+)
+
+var reloaderCtxKey = MakeContextKey[*reloader]("cmdr.reloader")
+
+// SignalHandler responds to a signal received by the process. Signal
+// handlers run with the base context of the command (see
+// srv.GetBaseContext).
+type SignalHandler func(context.Context, os.Signal) error
+
+type signalHandler struct {
+	sig     os.Signal
+	handler SignalHandler
+}
+
+// HandleSignal registers handlers for the signal. While the command
+// runs, a service managed by the srv.Orchestrator receives the
+// signals and runs their handlers, in the order they were
+// registered. Errors from the handlers do not stop the command, and
+// are returned by Run when the command returns. Register signal
+// handlers on the root commander.
+func (c *Commander) HandleSignal(sig os.Signal, handlers ...SignalHandler) *Commander {
+	c.signals.With(func(in *dt.List[signalHandler]) {
+		for _, h := range handlers {
+			erc.InvariantOk(h != nil, "signal handlers must not be nil")
+			in.PushBack(signalHandler{sig: sig, handler: h})
+		}
+	})
+	return c
+}
+
+// ConfigHooks adds hooks that run, like other hooks, before the
+// command's middleware and action, and that run again, with the base
+// context of the command, when the command reloads its
+// configuration. Use Reload to reload the configuration, or
+// SignalOptions to reload when the process receives SIGHUP.
+func (c *Commander) ConfigHooks(hooks ...Action) *Commander {
+	for _, hook := range hooks {
+		c.Hooks(func(ctx context.Context, cc *cli.Command) error {
+			if err := hook(ctx, cc); err != nil {
+				return err
+			}
+			if r, ok := reloaderCtxKey.Get(c.getContext()); ok {
+				r.addHook(func(ctx context.Context) error { return hook(ctx, cc) })
+			}
+			return nil
+		})
+	}
+	return c
+}
+
+// OnReload subscribes to the reloads of the configuration of the
+// command: after the config hooks of the command run successfully,
+// the subscribers run, in the order they subscribed. The context
+// must be (or derive from) the context of a command run by Run or
+// Main.
+func OnReload(ctx context.Context, subscriber func(context.Context) error) error {
+	r, ok := reloaderCtxKey.Get(ctx)
+	if !ok {
+		return fmt.Errorf("reloading the configuration: %w", ErrNotDefined)
+	}
+	erc.InvariantOk(subscriber != nil, "reload subscribers must not be nil")
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.subscribers = append(r.subscribers, subscriber)
+	return nil
+}
+
+// Reload re-runs the config hooks of the command and then, if all of
+// the hooks succeed, notifies the subscribers. Reloads do not run
+// concurrently.
+func Reload(ctx context.Context) error {
+	r, ok := reloaderCtxKey.Get(ctx)
+	if !ok {
+		return fmt.Errorf("reloading the configuration: %w", ErrNotDefined)
+	}
+	return r.reload(ctx)
+}
+
+type reloader struct {
+	running     sync.Mutex
+	mtx         sync.Mutex
+	hooks       []func(context.Context) error
+	subscribers []func(context.Context) error
+}
+
+func (r *reloader) addHook(hook func(context.Context) error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+func (r *reloader) reload(ctx context.Context) error {
+	r.running.Lock()
+	defer r.running.Unlock()
+
+	r.mtx.Lock()
+	hooks := slices.Clone(r.hooks)
+	r.mtx.Unlock()
+
+	for _, hook := range hooks {
+		if err := hook(ctx); err != nil {
+			return fmt.Errorf("reloading the configuration: %w", err)
+		}
+	}
+
+	r.mtx.Lock()
+	subscribers := slices.Clone(r.subscribers)
+	r.mtx.Unlock()
+
+	var ec erc.Collector
+	for _, sub := range subscribers {
+		ec.Push(sub(ctx))
+	}
+	return ec.Resolve()
+}
+
+func (c *Commander) attachReloader(ctx context.Context) context.Context {
+	if reloaderCtxKey.Has(ctx) {
+		return ctx
+	}
+	return reloaderCtxKey.Set(ctx, &reloader{})
+}
+
+// startSignalHandlers starts the service that runs the commander's
+// signal handlers.
+func (c *Commander) startSignalHandlers(ctx context.Context) error {
+	var handlers []signalHandler
+	c.signals.With(func(in *dt.List[signalHandler]) { handlers = slices.Collect(in.IteratorFront()) })
+	if len(handlers) == 0 || !srv.HasOrchestrator(ctx) {
+		return nil
+	}
+
+	sigs := dt.Set[os.Signal]{}
+	for _, h := range handlers {
+		sigs.Add(h.sig)
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, slices.Collect(sigs.Iterator())...)
+
+	err := srv.GetOrchestrator(ctx).Add(&srv.Service{
+		Name: "signals",
+		Run: func(sctx context.Context) error {
+			defer signal.Stop(ch)
+
+			var ec erc.Collector
+			for {
+				select {
+				case <-sctx.Done():
+					return ec.Resolve()
+				case sig := <-ch:
+					for _, h := range handlers {
+						if h.sig == sig {
+							ec.Wrapf(h.handler(srv.GetBaseContext(ctx), sig), "handling %s", sig)
+						}
+					}
+				}
+			}
+		},
+	})
+	if err != nil {
+		signal.Stop(ch)
+	}
+	return err
+}
+
+// ReloadHandler is a SignalHandler that reloads the configuration of
+// the command.
+func ReloadHandler() SignalHandler {
+	return func(ctx context.Context, _ os.Signal) error { return Reload(ctx) }
+}
+
+// DumpHandler is a SignalHandler that writes the state of the
+// process to the writer: the stacks of all goroutines, the metrics,
+// and the readiness of the command, if the command has metrics or
+// health endpoints.
+func DumpHandler(w io.Writer) SignalHandler {
+	return func(ctx context.Context, sig os.Signal) error {
+		var ec erc.Collector
+		_, err := fmt.Fprintf(w, "=== %s received at %s (pid %d)\n", sig, time.Now().UTC().Format(time.RFC3339), os.Getpid())
+		ec.Push(err)
+
+		if h, ok := HealthFromContext(ctx); ok {
+			status := "ready"
+			if err := h.Ready(ctx); err != nil {
+				status = err.Error()
+			}
+			_, err := fmt.Fprintf(w, "\n=== health\n%s\n", status)
+			ec.Push(err)
+		}
+
+		if m, ok := MetricsFromContext(ctx); ok {
+			_, err := fmt.Fprint(w, "\n=== metrics\n")
+			ec.Push(err)
+			_, err = m.WriteTo(w)
+			ec.Push(err)
+		}
+
+		_, err = fmt.Fprint(w, "\n=== goroutines\n")
+		ec.Push(err)
+		ec.Push(pprof.Lookup("goroutine").WriteTo(w, 2))
+		return ec.Resolve()
+	}
+}
+
+// SignalOptions configures the default signal handlers for a
+// command: reloading the configuration (see ConfigHooks and
+// OnReload), and dumping the state of the process. On Unix systems
+// the defaults are SIGHUP and SIGUSR1; elsewhere there are no
+// default signals.
+type SignalOptions struct {
+	// Reload are the signals that reload the configuration.
+	Reload []os.Signal
+	// Dump are the signals that dump the state of the process.
+	Dump []os.Signal
+	// DumpWriter receives the dumps. Defaults to standard error.
+	DumpWriter io.Writer
+}
+
+// Signals enables the default signal handlers for the commander.
+func Signals() Attachment { return SignalOptions{}.Add }
+
+// Add registers the signal handlers with the commander. Use with the
+// Commander.With method.
+func (opts SignalOptions) Add(c *Commander) {
+	if opts.Reload == nil {
+		opts.Reload = defaultReloadSignals
+	}
+	if opts.Dump == nil {
+		opts.Dump = defaultDumpSignals
+	}
+	if opts.DumpWriter == nil {
+		opts.DumpWriter = os.Stderr
+	}
+
+	for _, sig := range opts.Reload {
+		c.HandleSignal(sig, ReloadHandler())
+	}
+	for _, sig := range opts.Dump {
+		c.HandleSignal(sig, DumpHandler(opts.DumpWriter))
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package cmdr
+
+import "os"
+
+var (
+	defaultReloadSignals = []os.Signal{}
+	defaultDumpSignals   = []os.Signal{}
+)
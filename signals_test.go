@@ -0,0 +1,145 @@
+//go:build unix
+
+package cmdr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func TestSignals(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("Reload", func(t *testing.T) {
+		t.Run("NotRunning", func(t *testing.T) {
+			assert.ErrorIs(t, Reload(ctx), ErrNotDefined)
+			assert.ErrorIs(t, OnReload(ctx, func(context.Context) error { return nil }), ErrNotDefined)
+		})
+		t.Run("HooksAndSubscribers", func(t *testing.T) {
+			var loads, notified atomic.Int64
+			var value atomic.Value
+			value.Store("initial")
+
+			cmd := MakeRootCommander().SetName("tool").
+				Flags(MakeFlag(&FlagOptions[string]{Name: "name", Default: "one"})).
+				ConfigHooks(func(_ context.Context, cc *cli.Command) error {
+					loads.Add(1)
+					value.Store(cc.String("name"))
+					return nil
+				}).
+				Subcommanders(MakeCommander().SetName("sub").SetAction(func(ctx context.Context, _ *cli.Command) error {
+					check.Equal(t, loads.Load(), 1)
+					assert.NotError(t, OnReload(ctx, func(context.Context) error { notified.Add(1); return nil }))
+
+					value.Store("changed")
+					assert.NotError(t, Reload(ctx))
+					check.Equal(t, loads.Load(), 2)
+					check.Equal(t, notified.Load(), 1)
+					check.Equal(t, value.Load().(string), "one")
+					return nil
+				}))
+
+			assert.NotError(t, Run(ctx, cmd, []string{"tool", "sub"}))
+		})
+		t.Run("HookError", func(t *testing.T) {
+			var fail atomic.Bool
+			var notified atomic.Int64
+			cmd := MakeRootCommander().SetName("tool").
+				ConfigHooks(func(context.Context, *cli.Command) error {
+					if fail.Load() {
+						return errors.New("invalid config")
+					}
+					return nil
+				}).
+				SetAction(func(ctx context.Context, _ *cli.Command) error {
+					assert.NotError(t, OnReload(ctx, func(context.Context) error { notified.Add(1); return nil }))
+					fail.Store(true)
+					err := Reload(ctx)
+					assert.Error(t, err)
+					check.Substring(t, err.Error(), "invalid config")
+					check.Equal(t, notified.Load(), 0)
+					return nil
+				})
+			assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+		})
+	})
+	t.Run("HandleSignal", func(t *testing.T) {
+		t.Run("NilHandler", func(t *testing.T) {
+			assert.Panic(t, func() { MakeRootCommander().HandleSignal(syscall.SIGHUP, nil) })
+		})
+		t.Run("Delivery", func(t *testing.T) {
+			received := make(chan os.Signal, 1)
+			cmd := MakeRootCommander().SetName("tool").
+				HandleSignal(syscall.SIGUSR2, func(ctx context.Context, sig os.Signal) error {
+					received <- sig
+					return errors.New("handler failed")
+				}).
+				SetAction(func(context.Context, *cli.Command) error {
+					assert.NotError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+					select {
+					case sig := <-received:
+						check.Equal(t, sig, os.Signal(syscall.SIGUSR2))
+					case <-time.After(5 * time.Second):
+						t.Error("signal not handled")
+					}
+					return nil
+				})
+
+			err := Run(ctx, cmd, []string{"tool"})
+			assert.Error(t, err)
+			check.Substring(t, err.Error(), "handler failed")
+		})
+	})
+	t.Run("Defaults", func(t *testing.T) {
+		buf := &syncBuffer{}
+		var notified atomic.Int64
+		done := make(chan struct{})
+		cmd := MakeRootCommander().SetName("tool").
+			With(SignalOptions{DumpWriter: buf}.Add).
+			With(WithMetrics()).
+			ConfigHooks(func(context.Context, *cli.Command) error { return nil }).
+			SetAction(func(ctx context.Context, _ *cli.Command) error {
+				assert.NotError(t, OnReload(ctx, func(context.Context) error {
+					notified.Add(1)
+					close(done)
+					return nil
+				}))
+
+				assert.NotError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+				select {
+				case <-done:
+				case <-time.After(5 * time.Second):
+					t.Error("reload not handled")
+				}
+
+				assert.NotError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+				for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+					if bytes.Contains(buf.Bytes(), []byte("=== goroutines")) {
+						break
+					}
+				}
+				return nil
+			})
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+		check.Equal(t, notified.Load(), 1)
+		out := buf.String()
+		check.Substring(t, out, "user defined signal 1 received")
+		check.Substring(t, out, "=== metrics")
+		check.Substring(t, out, "cmdr_phase_duration_seconds")
+		check.Substring(t, out, "=== goroutines")
+		check.Substring(t, out, "TestSignals")
+	})
+}
@@ -0,0 +1,13 @@
+//go:build unix
+
+package cmdr
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	defaultReloadSignals = []os.Signal{syscall.SIGHUP}
+	defaultDumpSignals   = []os.Signal{syscall.SIGUSR1}
+)
@@ -0,0 +1,115 @@
+package cmdr
+
+import (
+	"context"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+)
+
+var streamsCtxKey = MakeContextKey[Streams]("cmdr.streams")
+
+// Streams are the input and outputs of a command. Configure them on
+// the root commander with SetReader, SetWriter, and SetErrWriter
+// (e.g. to capture the output in tests, or to embed the command in
+// another program,) and access them in operations with
+// StreamsFromContext.
+type Streams struct {
+	In  io.Reader
+	Out io.Writer
+	Err io.Writer
+}
+
+// StreamsFromContext returns the streams of the running command, or
+// the standard input, output, and error of the process when the
+// context does not come from a command.
+func StreamsFromContext(ctx context.Context) Streams {
+	s, _ := streamsCtxKey.Get(ctx)
+	s.In = secondValueWhenFirstIsZero[io.Reader](s.In, os.Stdin)
+	s.Out = secondValueWhenFirstIsZero[io.Writer](s.Out, os.Stdout)
+	s.Err = secondValueWhenFirstIsZero[io.Writer](s.Err, os.Stderr)
+	return s
+}
+
+// SetReader sets the input of the command. Defaults to standard
+// input. Only the streams of the root commander are used.
+func (c *Commander) SetReader(r io.Reader) *Commander {
+	c.streams.With(func(s *Streams) { s.In = r })
+	return c
+}
+
+// SetWriter sets the output of the command, which is also where the
+// help is written. Defaults to standard output. Only the streams of
+// the root commander are used.
+func (c *Commander) SetWriter(w io.Writer) *Commander {
+	c.streams.With(func(s *Streams) { s.Out = w })
+	return c
+}
+
+// SetErrWriter sets the error output of the command, which is also
+// where prompts and progress are written. Defaults to standard
+// error. Only the streams of the root commander are used.
+func (c *Commander) SetErrWriter(w io.Writer) *Commander {
+	c.streams.With(func(s *Streams) { s.Err = w })
+	return c
+}
+
+// attachStreams attaches the streams of the root command to the
+// context.
+func attachStreams(ctx context.Context, cc *cli.Command) context.Context {
+	if streamsCtxKey.Has(ctx) {
+		return ctx
+	}
+	root := cc.Root()
+	return streamsCtxKey.Set(ctx, Streams{In: root.Reader, Out: root.Writer, Err: root.ErrWriter})
+}
+
+// Terminal describes the capabilities of the terminal that a stream
+// is connected to.
+type Terminal struct {
+	// TTY reports whether the stream is a terminal that supports
+	// control sequences: streams are not terminals when TERM is
+	// "dumb".
+	TTY bool
+	// Width is the number of columns of the terminal, or, when
+	// the size is not available, of the COLUMNS environment
+	// variable. Width is zero when it is not known, and for
+	// streams that are not terminals.
+	Width int
+	// Color reports whether the terminal should use colors: it is
+	// false when the NO_COLOR environment variable is set (see
+	// https://no-color.org.)
+	Color bool
+}
+
+// DetectTerminal describes the terminal that the stream is connected
+// to. Only files (and other streams with file descriptors, that
+// implement Fd() uintptr) are terminals.
+func DetectTerminal(stream any) Terminal {
+	fd, ok := terminalFd(stream)
+	if !ok || os.Getenv("TERM") == "dumb" {
+		return Terminal{}
+	}
+
+	t := Terminal{TTY: true, Color: os.Getenv("NO_COLOR") == ""}
+	if width, _, err := term.GetSize(fd); err == nil && width > 0 {
+		t.Width = width
+	} else if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		t.Width = width
+	}
+	return t
+}
+
+// terminalFd returns the file descriptor of the stream, when the
+// stream is a terminal.
+func terminalFd(stream any) (int, bool) {
+	f, ok := stream.(interface{ Fd() uintptr })
+	if !ok {
+		return -1, false
+	}
+	fd := int(f.Fd())
+	return fd, term.IsTerminal(fd)
+}
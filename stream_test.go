@@ -0,0 +1,80 @@
+package cmdr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+func TestStreams(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Default", func(t *testing.T) {
+		s := StreamsFromContext(ctx)
+		check.True(t, s.In == os.Stdin)
+		check.True(t, s.Out == os.Stdout)
+		check.True(t, s.Err == os.Stderr)
+	})
+	t.Run("Commander", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		sub := MakeCommander().SetName("echo").
+			SetAction(func(ctx context.Context, _ *cli.Command) error {
+				s := StreamsFromContext(ctx)
+				data, err := io.ReadAll(s.In)
+				if err != nil {
+					return err
+				}
+				fmt.Fprint(s.Out, strings.ToUpper(string(data)))
+				fmt.Fprint(s.Err, "done")
+				return nil
+			})
+		cmd := MakeRootCommander().SetName("tool").
+			SetReader(strings.NewReader("hello\n")).
+			SetWriter(stdout).
+			SetErrWriter(stderr).
+			Subcommanders(sub)
+
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "echo"}))
+		check.Equal(t, stdout.String(), "HELLO\n")
+		check.Equal(t, stderr.String(), "done")
+	})
+	t.Run("Help", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		cmd := MakeRootCommander().SetName("tool").SetUsage("does things").SetWriter(stdout).
+			SetAction(func(context.Context, *cli.Command) error { return nil })
+		assert.NotError(t, Run(ctx, cmd, []string{"tool", "--help"}))
+		check.Substring(t, stdout.String(), "does things")
+	})
+	t.Run("NotInteractive", func(t *testing.T) {
+		cmd := MakeRootCommander().SetName("tool").
+			SetReader(strings.NewReader("y\n")).
+			With(WithPrompts()).
+			SetAction(func(ctx context.Context, _ *cli.Command) error {
+				_, ok := PrompterFromContext(ctx)
+				check.True(t, !ok)
+				return nil
+			})
+		assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+	})
+}
+
+func TestDetectTerminal(t *testing.T) {
+	check.Equal(t, DetectTerminal(&bytes.Buffer{}), Terminal{})
+	check.Equal(t, DetectTerminal(nil), Terminal{})
+
+	r, w, err := os.Pipe()
+	assert.NotError(t, err)
+	defer r.Close()
+	defer w.Close()
+	check.Equal(t, DetectTerminal(r), Terminal{})
+	check.Equal(t, DetectTerminal(w), Terminal{})
+}
@@ -0,0 +1,187 @@
+package cmdr
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tychoish/fun/dt"
+	"github.com/tychoish/fun/erc"
+)
+
+const tracerName = "github.com/tychoish/cmdr"
+
+var (
+	tracerCtxKey   = MakeContextKey[trace.Tracer]("cmdr.tracer")
+	rootSpanCtxKey = MakeContextKey[trace.Span]("cmdr.root-span")
+)
+
+// TracingOptions configures tracing for a commander. When tracing is
+// enabled every invocation of the command produces a root span,
+// named for the command (e.g. "tool deploy"), with child spans for
+// each hook and middleware, named for their position (e.g. "hook 0"
+// and "middleware 1") or, for middleware added with AddMiddleware,
+// for their name (e.g. "middleware health"), and for the action.
+// The names (but never the values) of the flags set on the command
+// are recorded as attributes, and errors are recorded on the spans
+// where they occur.
+//
+// The context passed to the action, and therefore to Operations, has
+// the span for the action, so that spans created by the operation
+// with the OpenTelemetry API are children of the command's spans.
+type TracingOptions struct {
+	// TracerProvider, when set, is used for all spans. When nil,
+	// the commander has a flag that writes the spans as JSON to
+	// a file, or to standard error when the value of the flag is
+	// "-", and tracing is only enabled when the flag is set.
+	TracerProvider trace.TracerProvider
+	// FlagName is the name of the flag used when the
+	// TracerProvider is nil. Defaults to "trace-file".
+	FlagName string
+}
+
+// Tracing enables tracing, using the default options, for the
+// commander. Attach tracing to the root commander.
+func Tracing() Attachment { return TracingOptions{}.Add }
+
+// Add attaches tracing to the commander. Use with the
+// Commander.With method.
+func (opts TracingOptions) Add(c *Commander) {
+	opts.FlagName = secondValueWhenFirstIsZero(opts.FlagName, "trace-file")
+	if opts.TracerProvider == nil {
+		c.Flags(MakeFlag(&FlagOptions[string]{
+			Name:      opts.FlagName,
+			Usage:     "write trace spans as JSON to this file ('-' for standard error)",
+			TakesFile: true,
+		}))
+	}
+
+	var shutdown func(context.Context) error
+
+	c.hook.With(func(in *dt.List[Action]) {
+		in.PushFront(func(_ context.Context, cc *cli.Command) error {
+			provider := opts.TracerProvider
+			if provider == nil {
+				var err error
+				provider, shutdown, err = fileTracerProvider(cc.String(opts.FlagName))
+				if err != nil || provider == nil {
+					return err
+				}
+			}
+
+			tracer := provider.Tracer(tracerName)
+			ctx, span := tracer.Start(c.getContext(), cc.FullName())
+			c.setContext(tracerCtxKey.Set(rootSpanCtxKey.Set(ctx, span), tracer))
+			return nil
+		})
+	})
+
+	after := c.cmd.After
+	c.cmd.After = func(ctx context.Context, cc *cli.Command) error {
+		var ec erc.Collector
+		if after != nil {
+			ec.Push(after(ctx, cc))
+		}
+
+		if span, ok := rootSpanCtxKey.Get(c.getContext()); ok {
+			span.End()
+		}
+		if shutdown != nil {
+			ec.Push(shutdown(context.Background()))
+		}
+		return ec.Resolve()
+	}
+}
+
+// fileTracerProvider builds a provider that writes spans to the
+// file, returning a nil provider if the path is empty.
+func fileTracerProvider(path string) (trace.TracerProvider, func(context.Context) error, error) {
+	var out io.WriteCloser
+	switch path {
+	case "":
+		return nil, nil, nil
+	case "-":
+		out = nopWriteCloser{Writer: os.Stderr}
+	default:
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		out = file
+	}
+
+	exp, err := stdouttrace.New(stdouttrace.WithWriter(out))
+	if err != nil {
+		return nil, nil, erc.Join(err, out.Close())
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	return tp, func(ctx context.Context) error { return erc.Join(tp.Shutdown(ctx), out.Close()) }, nil
+}
+
+// startSpan starts a span for a phase of the execution of a command,
+// when tracing is enabled. The returned function ends the span,
+// recording the error, if any.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	tracer, ok := tracerCtxKey.Get(ctx)
+	if !ok {
+		return ctx, func(error) {}
+	}
+
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		recordError(span, err)
+		span.End()
+	}
+}
+
+// endPhase returns the context produced by a phase with the span of
+// the parent context, so that the spans of later phases are not
+// children of the (ended) span of the phase.
+func endPhase(ctx, parent context.Context) context.Context {
+	if !tracerCtxKey.Has(parent) {
+		return ctx
+	}
+	return trace.ContextWithSpan(ctx, trace.SpanFromContext(parent))
+}
+
+// traceAction runs the action in a span, naming the root span for the
+// command that runs and recording the names of the flags that are
+// set.
+func traceAction(ctx context.Context, cc *cli.Command, op Action) error {
+	root, ok := rootSpanCtxKey.Get(ctx)
+	if ok {
+		root.SetName(cc.FullName())
+		root.SetAttributes(
+			attribute.String("cmdr.command", cc.FullName()),
+			attribute.StringSlice("cmdr.flags", cc.FlagNames()),
+		)
+	}
+
+	ctx, end := startSpan(ctx, "action", attribute.String("cmdr.command", cc.FullName()))
+	err := op(ctx, cc)
+	end(err)
+	if ok {
+		recordError(root, err)
+	}
+	return err
+}
+
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,129 @@
+package cmdr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tychoish/fun/assert"
+	"github.com/tychoish/fun/assert/check"
+)
+
+type testSpan struct {
+	Name        string
+	SpanContext struct {
+		TraceID string
+		SpanID  string
+	}
+	Parent struct {
+		SpanID string
+	}
+	Attributes []struct {
+		Key   string
+		Value struct{ Value any }
+	}
+	Status struct{ Code string }
+}
+
+func readSpans(t *testing.T, path string) map[string]testSpan {
+	t.Helper()
+	file, err := os.Open(path)
+	assert.NotError(t, err)
+	defer file.Close()
+
+	out := map[string]testSpan{}
+	dec := json.NewDecoder(file)
+	for {
+		var span testSpan
+		if err := dec.Decode(&span); errors.Is(err, io.EOF) {
+			break
+		} else {
+			assert.NotError(t, err)
+		}
+		_, dup := out[span.Name]
+		check.True(t, !dup)
+		out[span.Name] = span
+	}
+	return out
+}
+
+func TestTracing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	build := func(actionErr error) *Commander {
+		sub := MakeCommander().SetName("sub").
+			Flags(MakeFlag(&FlagOptions[string]{Name: "password", Secret: true})).
+			SetAction(func(ctx context.Context, cc *cli.Command) error {
+				check.True(t, trace.SpanFromContext(ctx).SpanContext().IsValid())
+				return actionErr
+			})
+		return MakeRootCommander().SetName("tool").
+			Hooks(
+				func(context.Context, *cli.Command) error { return nil },
+				func(context.Context, *cli.Command) error { return nil },
+			).
+			With(Tracing()).
+			AddMiddleware(MiddlewareOptions{
+				Name: "child",
+				Middleware: func(ctx context.Context, _ *cli.Command) (context.Context, error) {
+					_, span := trace.SpanFromContext(ctx).TracerProvider().Tracer("test").Start(ctx, "inner")
+					span.End()
+					return ctx, nil
+				},
+			}).
+			Subcommanders(sub)
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		cmd := MakeRootCommander().SetName("tool").With(Tracing()).
+			SetAction(func(ctx context.Context, cc *cli.Command) error {
+				check.True(t, !trace.SpanFromContext(ctx).SpanContext().IsValid())
+				return nil
+			})
+		assert.NotError(t, Run(ctx, cmd, []string{"tool"}))
+	})
+	t.Run("Spans", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "trace.json")
+		assert.NotError(t, Run(ctx, build(nil), []string{"tool", "--trace-file", path, "sub", "--password", "hunter2"}))
+
+		spans := readSpans(t, path)
+		root, ok := spans["tool sub"]
+		assert.True(t, ok)
+		// the tracing hook is the first hook, and starts the
+		// root span.
+		_, ok = spans["hook 0"]
+		check.True(t, !ok)
+		for _, name := range []string{"hook 1", "hook 2", "middleware 0", "action"} {
+			span, ok := spans[name]
+			assert.True(t, ok)
+			check.Equal(t, span.SpanContext.TraceID, root.SpanContext.TraceID)
+			check.Equal(t, span.Parent.SpanID, root.SpanContext.SpanID)
+		}
+
+		inner, ok := spans["inner"]
+		assert.True(t, ok)
+		check.Equal(t, inner.Parent.SpanID, spans["middleware child"].SpanContext.SpanID)
+		check.Equal(t, spans["middleware child"].Parent.SpanID, root.SpanContext.SpanID)
+
+		data, err := os.ReadFile(path)
+		assert.NotError(t, err)
+		check.Substring(t, string(data), "password")
+		check.NotSubstring(t, string(data), "hunter2")
+	})
+	t.Run("Errors", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "trace.json")
+		assert.Error(t, Run(ctx, build(errors.New("kip")), []string{"tool", "--trace-file", path, "sub"}))
+
+		spans := readSpans(t, path)
+		check.Equal(t, spans["action"].Status.Code, "Error")
+		check.Equal(t, spans["tool sub"].Status.Code, "Error")
+	})
+}